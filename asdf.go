@@ -0,0 +1,111 @@
+// Package asdf embeds the WebFinger server in another Go program. The asdf
+// command serves the same endpoints configured from the environment.
+//
+//	store, err := asdf.NewFileStore("data/data.json")
+//	...
+//	srv, err := asdf.New(asdf.Options{Store: store, Domains: []string{"example.com"}})
+//	...
+//	mux.Handle("/.well-known/webfinger", srv.Handler())
+package asdf
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/0dayfall/asdf/internal/api"
+	"github.com/0dayfall/asdf/internal/config"
+	"github.com/0dayfall/asdf/internal/db"
+	"github.com/0dayfall/asdf/internal/resource"
+	"github.com/0dayfall/asdf/internal/server"
+)
+
+type (
+	JRD  = api.JRD
+	Link = api.Link
+
+	// Store holds the records served. LookupResource is called with the
+	// canonical form of the requested resource, see CanonicalSubject, and
+	// returns nil for unknown resources.
+	Store = db.Store
+)
+
+// CanonicalSubject returns the form of a resource that records are matched
+// on, for Store implementations to index their records by.
+func CanonicalSubject(res string) (string, error) {
+	return resource.GetSubject(res)
+}
+
+// NewFileStore returns a Store with the records of a JSON file in the format
+// of data/data.json.
+func NewFileStore(fileName string) (Store, error) {
+	data := db.NewData()
+	if err := data.LoadData(fileName); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Options configure a Server.
+type Options struct {
+	// Store is required.
+	Store Store
+	// Domains restricts lookups to accounts at these domains, all domains
+	// are served when empty.
+	Domains []string
+	// Pages also serves the HTML search pages at / with their assets
+	// below /static, both embedded in the binary.
+	Pages bool
+
+	// Addr, CertFile and KeyFile are only used by Run. Without a
+	// certificate Run serves plain HTTP, for use behind a TLS proxy.
+	Addr     string
+	CertFile string
+	KeyFile  string
+}
+
+// Server answers WebFinger requests for the records of a Store. It is the
+// server of the asdf command, configured by Options instead of the
+// environment.
+type Server struct {
+	server *server.Server
+}
+
+// New builds a Server from opts. Options.Store is required, New returns an
+// error without it. Nothing is served until Handler is mounted or Run is
+// called.
+func New(opts Options) (*Server, error) {
+	if opts.Store == nil {
+		return nil, errors.New("asdf: Options.Store is required")
+	}
+	cfg := &config.Config{
+		Environment:   "development",
+		Addr:          opts.Addr,
+		CertPath:      opts.CertFile,
+		KeyPath:       opts.KeyFile,
+		ServedDomains: opts.Domains,
+	}
+	serverOpts := []server.Option{server.WithStore(opts.Store)}
+	if !opts.Pages {
+		serverOpts = append(serverOpts, server.WithoutPages())
+	}
+	s, err := server.New(cfg, serverOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{server: s}, nil
+}
+
+// Handler returns the handler serving /.well-known/webfinger, and / with
+// Options.Pages, for mounting on an existing mux at those paths. It also
+// answers the /health, /metrics and /api documentation routes of the asdf
+// command.
+func (s *Server) Handler() http.Handler {
+	return s.server.Handler()
+}
+
+// Run serves Handler on Options.Addr until ctx is done and then shuts down
+// gracefully.
+func (s *Server) Run(ctx context.Context) error {
+	return s.server.Run(ctx)
+}
@@ -0,0 +1,83 @@
+package asdf
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// mapStore is a Store as an embedding program might write one.
+type mapStore map[string]JRD
+
+func (m mapStore) LookupResource(subject string) (*JRD, error) {
+	jrd, ok := m[subject]
+	if !ok {
+		return nil, nil
+	}
+	return &jrd, nil
+}
+
+func (m mapStore) Records() []JRD {
+	records := make([]JRD, 0, len(m))
+	for _, jrd := range m {
+		records = append(records, jrd)
+	}
+	return records
+}
+
+func TestHandlerMountedOnMux(t *testing.T) {
+	// Arrange
+	subject, err := CanonicalSubject("acct:Alice@example.com")
+	require.NoError(t, err)
+	store := mapStore{subject: {Subject: "acct:alice@example.com"}}
+	srv, err := New(Options{Store: store, Domains: []string{"example.com"}})
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	mux.Handle("/.well-known/webfinger", srv.Handler())
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	// Act
+	found := httptest.NewRecorder()
+	mux.ServeHTTP(found, httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?resource=acct:alice@example.com", nil))
+	other := httptest.NewRecorder()
+	mux.ServeHTTP(other, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// Assert
+	require.Equal(t, http.StatusOK, found.Code)
+	var jrd JRD
+	require.NoError(t, json.Unmarshal(found.Body.Bytes(), &jrd))
+	require.Equal(t, "acct:alice@example.com", jrd.Subject)
+	require.Equal(t, http.StatusTeapot, other.Code)
+}
+
+func TestNewRequiresStore(t *testing.T) {
+	_, err := New(Options{})
+	require.Error(t, err)
+}
+
+func TestRunStopsWithContext(t *testing.T) {
+	// Arrange
+	srv, err := New(Options{Store: mapStore{}, Addr: "127.0.0.1:0"})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	// Act
+	go func() { done <- srv.Run(ctx) }()
+	cancel()
+
+	// Assert
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the context was canceled")
+	}
+}
@@ -0,0 +1,258 @@
+// Package client looks up WebFinger resources (RFC 7033) on remote hosts,
+// returning the same JRD type the asdf server produces.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/0dayfall/asdf/internal/api"
+	"github.com/0dayfall/asdf/internal/resource"
+)
+
+// JRD and Link are the types served by asdf.
+type (
+	JRD  = api.JRD
+	Link = api.Link
+)
+
+// ErrNotFound is returned when neither WebFinger nor host-meta know the
+// resource.
+var ErrNotFound = errors.New("webfinger: resource not found")
+
+// maxBodySize bounds the documents read from remote hosts.
+const maxBodySize = 1 << 20
+
+type options struct {
+	rels       []string
+	httpClient *http.Client
+}
+
+// Option configures a Lookup.
+type Option func(*options)
+
+// WithRels asks only for links with these relation types. Servers may
+// ignore the request, so links are filtered on the client as well.
+func WithRels(rels ...string) Option {
+	return func(o *options) {
+		o.rels = append(o.rels, rels...)
+	}
+}
+
+// WithHTTPClient uses client for requests. Its redirect policy is replaced
+// by one that only follows redirects to HTTPS. A nil client is ignored.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		if client != nil {
+			o.httpClient = client
+		}
+	}
+}
+
+// Lookup resolves resource, such as "acct:user@host" or "https://host/path",
+// by querying the WebFinger endpoint of its host. If the host has none, the
+// lrdd template of its host-meta document is used instead.
+func Lookup(ctx context.Context, resource string, opts ...Option) (*JRD, error) {
+	o := options{httpClient: &http.Client{Timeout: 10 * time.Second}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	client := *o.httpClient
+	client.CheckRedirect = httpsRedirectsOnly
+
+	host, err := Host(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{"resource": {resource}}
+	for _, rel := range o.rels {
+		query.Add("rel", rel)
+	}
+	endpoint := "https://" + host + "/.well-known/webfinger?" + query.Encode()
+	jrd, err := getJRD(ctx, &client, endpoint)
+	if errors.Is(err, ErrNotFound) {
+		jrd, err = lookupHostMeta(ctx, &client, host, resource)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return filterRels(jrd, o.rels), nil
+}
+
+// Host returns the host to query for resource. Hosts are checked as the
+// server checks them, see resource.CanonicalHost, so a resource can only
+// name a bare hostname or IP literal, and a port for http: and https: URIs.
+func Host(res string) (string, error) {
+	scheme, rest, ok := strings.Cut(res, ":")
+	if !ok {
+		return "", fmt.Errorf("webfinger: resource %q has no scheme", res)
+	}
+	switch strings.ToLower(scheme) {
+	case "acct", "mailto":
+		at := strings.LastIndex(rest, "@")
+		if at < 0 || at == len(rest)-1 {
+			return "", fmt.Errorf("webfinger: resource %q has no host", res)
+		}
+		host, ok := resource.CanonicalHost(rest[at+1:])
+		if !ok {
+			return "", fmt.Errorf("webfinger: resource %q has an invalid host", res)
+		}
+		if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+			host = "[" + host + "]"
+		}
+		return host, nil
+	default:
+		u, err := url.Parse(res)
+		if err != nil || u.Host == "" {
+			return "", fmt.Errorf("webfinger: resource %q has no host", res)
+		}
+		if u.User != nil {
+			return "", fmt.Errorf("webfinger: resource %q has userinfo in its host", res)
+		}
+		host, ok := resource.CanonicalHost(u.Hostname())
+		if !ok {
+			return "", fmt.Errorf("webfinger: resource %q has an invalid host", res)
+		}
+		if port := u.Port(); port != "" {
+			return net.JoinHostPort(host, port), nil
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		return host, nil
+	}
+}
+
+func httpsRedirectsOnly(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("webfinger: too many redirects")
+	}
+	if req.URL.Scheme != "https" {
+		return fmt.Errorf("webfinger: refusing redirect to %s", req.URL.Scheme)
+	}
+	return nil
+}
+
+func get(ctx context.Context, client *http.Client, endpoint, accept string) ([]byte, string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	request.Header.Set("Accept", accept)
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, "", fmt.Errorf("webfinger: %w", err)
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusGone:
+		return nil, "", ErrNotFound
+	case response.StatusCode != http.StatusOK:
+		return nil, "", fmt.Errorf("webfinger: %s answered %s", endpoint, response.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxBodySize))
+	if err != nil {
+		return nil, "", fmt.Errorf("webfinger: reading %s: %w", endpoint, err)
+	}
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	return body, mediaType, nil
+}
+
+func getJRD(ctx context.Context, client *http.Client, endpoint string) (*JRD, error) {
+	body, _, err := get(ctx, client, endpoint, "application/jrd+json, application/json")
+	if err != nil {
+		return nil, err
+	}
+	var jrd JRD
+	if err := json.Unmarshal(body, &jrd); err != nil {
+		return nil, fmt.Errorf("webfinger: decoding JRD from %s: %w", endpoint, err)
+	}
+	if jrd.Subject == "" && len(jrd.Links) == 0 {
+		return nil, ErrNotFound
+	}
+	return &jrd, nil
+}
+
+// xrd is the part of an RFC 6415 host-meta XRD document needed to find the
+// lrdd template.
+type xrd struct {
+	Links []struct {
+		Rel      string `xml:"rel,attr"`
+		Template string `xml:"template,attr"`
+	} `xml:"Link"`
+}
+
+// lookupHostMeta implements the RFC 6415 fallback: find the lrdd template in
+// host-meta.json or host-meta and query it for resource.
+func lookupHostMeta(ctx context.Context, client *http.Client, host, resource string) (*JRD, error) {
+	var template string
+	body, mediaType, err := get(ctx, client, "https://"+host+"/.well-known/host-meta.json", "application/json")
+	if err == nil && strings.Contains(mediaType, "json") {
+		var hostMeta struct {
+			Links []struct {
+				Rel      string `json:"rel"`
+				Template string `json:"template"`
+			} `json:"links"`
+		}
+		if json.Unmarshal(body, &hostMeta) == nil {
+			for _, link := range hostMeta.Links {
+				if link.Rel == "lrdd" && link.Template != "" {
+					template = link.Template
+				}
+			}
+		}
+	}
+	if template == "" {
+		body, _, err = get(ctx, client, "https://"+host+"/.well-known/host-meta", "application/xrd+xml")
+		if err != nil {
+			return nil, err
+		}
+		var hostMeta xrd
+		if err := xml.Unmarshal(body, &hostMeta); err != nil {
+			return nil, fmt.Errorf("webfinger: decoding host-meta of %s: %w", host, err)
+		}
+		for _, link := range hostMeta.Links {
+			if link.Rel == "lrdd" && link.Template != "" {
+				template = link.Template
+			}
+		}
+	}
+	if template == "" {
+		return nil, ErrNotFound
+	}
+
+	endpoint := strings.ReplaceAll(template, "{uri}", url.QueryEscape(resource))
+	if !strings.HasPrefix(endpoint, "https://") {
+		return nil, fmt.Errorf("webfinger: refusing non-HTTPS lrdd template %q", template)
+	}
+	return getJRD(ctx, client, endpoint)
+}
+
+func filterRels(jrd *JRD, rels []string) *JRD {
+	if len(rels) == 0 {
+		return jrd
+	}
+	links := make([]Link, 0, len(jrd.Links))
+	for _, link := range jrd.Links {
+		for _, rel := range rels {
+			if link.Rel == rel {
+				links = append(links, link)
+				break
+			}
+		}
+	}
+	jrd.Links = links
+	return jrd
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/0dayfall/asdf/internal/db"
+	"github.com/0dayfall/asdf/internal/rest"
+	"github.com/stretchr/testify/require"
+)
+
+// clientFor returns an HTTP client that sends every request to server,
+// whatever the host, trusting its certificate.
+func clientFor(server *httptest.Server) *http.Client {
+	client := server.Client()
+	transport := client.Transport.(*http.Transport)
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+	return client
+}
+
+func TestLookupAgainstServer(t *testing.T) {
+	// Arrange
+	data := db.NewData()
+	require.NoError(t, data.LoadData(path.Join("..", "internal", "rest", "test", "data.json")))
+	mux := http.NewServeMux()
+	(&rest.WebFingerHandler{Data: data}).RegisterRoutes(mux)
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+
+	// Act
+	jrd, err := Lookup(context.Background(), "acct:example@example.com",
+		WithHTTPClient(clientFor(server)), WithRels("http://example.com/rel/blog"))
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, "acct:example@example.com", jrd.Subject)
+	require.Equal(t, []Link{{Rel: "http://example.com/rel/blog", Type: "text/html", Href: "http://blogs.example.com/example/"}}, jrd.Links)
+}
+
+func TestLookupHostMetaFallback(t *testing.T) {
+	// Arrange
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/host-meta", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xrd+xml")
+		w.Write([]byte(`<?xml version="1.0"?><XRD xmlns="http://docs.oasis-open.org/ns/xri/xrd-1.0">` +
+			`<Link rel="lrdd" template="https://example.com/lrdd?uri={uri}"/></XRD>`))
+	})
+	mux.HandleFunc("/lrdd", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/jrd+json")
+		w.Write([]byte(`{"subject":"` + r.URL.Query().Get("uri") + `","links":[{"rel":"self","href":"https://example.com/alice"}]}`))
+	})
+	server := httptest.NewTLSServer(mux)
+	defer server.Close()
+
+	// Act
+	jrd, err := Lookup(context.Background(), "acct:alice@example.com", WithHTTPClient(clientFor(server)))
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, "acct:alice@example.com", jrd.Subject)
+	require.Equal(t, "https://example.com/alice", jrd.Links[0].Href)
+}
+
+func TestHost(t *testing.T) {
+	for resource, expected := range map[string]string{
+		"acct:alice@Example.com":       "example.com",
+		"mailto:alice@example.com":     "example.com",
+		"https://example.com:8443/bob": "example.com:8443",
+		"acct:josé@bücher.example":     "xn--bcher-kva.example",
+		"acct:alice@[2001:db8::1]":     "[2001:db8::1]",
+		"https://[2001:db8::1]/alice":  "[2001:db8::1]",
+	} {
+		host, err := Host(resource)
+		require.NoError(t, err, resource)
+		require.Equal(t, expected, host, resource)
+	}
+	for _, resource := range []string{
+		"alice@example.com",
+		"acct:alice",
+		"https:///path",
+		"acct:x@example.com/foo",
+		"acct:x@example.com:8443",
+		"acct:x@example.com#frag",
+		"acct:x@[<script>]",
+		"https://user@example.com/alice",
+		"https://exa_mple.com/alice",
+	} {
+		_, err := Host(resource)
+		require.Error(t, err, resource)
+	}
+}
+
+func TestWithHTTPClientNil(t *testing.T) {
+	// Arrange
+	o := options{httpClient: http.DefaultClient}
+
+	// Act
+	WithHTTPClient(nil)(&o)
+
+	// Assert
+	require.Same(t, http.DefaultClient, o.httpClient)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/0dayfall/asdf/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func configCommand() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+	configCmd.AddCommand(&cobra.Command{
+		Use:   "validate",
+		Short: "Check the configuration in the environment and report every problem",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := config.Load(); err != nil {
+				return fmt.Errorf("invalid configuration:\n%w", err)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "Configuration is valid")
+			return nil
+		},
+	})
+	return configCmd
+}
@@ -1,22 +1,22 @@
 package main
 
 import (
-	"asdf/internal/server"
-	"log"
 	"os"
+
+	"github.com/spf13/cobra"
 )
 
 func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		log.Fatal("$PORT must be set")
+	root := &cobra.Command{
+		Use:          "asdf",
+		Short:        "A WebFinger server",
+		SilenceUsage: true,
+		// Running without a subcommand serves, as the container image does
+		RunE: runServe,
 	}
+	root.AddCommand(serveCommand(), configCommand(), recordCommand())
 
-	certPath := os.Getenv("SSL_CERT_PATH")
-	keyPath := os.Getenv("SSL_KEY_PATH")
-	if certPath == "" || keyPath == "" {
-		log.Fatal("SSL certificate or key path not set in environment variables")
+	if err := root.Execute(); err != nil {
+		os.Exit(1)
 	}
-
-	server.Start(":"+port, certPath, keyPath)
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/0dayfall/asdf/internal/api"
+	"github.com/0dayfall/asdf/internal/config"
+	"github.com/0dayfall/asdf/internal/db"
+	"github.com/0dayfall/asdf/internal/resource"
+	"github.com/spf13/cobra"
+)
+
+func recordCommand() *cobra.Command {
+	recordCmd := &cobra.Command{
+		Use:   "record",
+		Short: "Manage WebFinger records in the data file",
+	}
+	dataFile := config.DefaultDataFile
+	if env := os.Getenv("DATA_FILE"); env != "" {
+		dataFile = env
+	}
+	recordCmd.PersistentFlags().String("data", dataFile, "data file holding the records")
+
+	var conflict string
+	var dryRun bool
+	importCmd := &cobra.Command{
+		Use:   "import FILE",
+		Short: "Import records from a JSON array of JRDs",
+		Long: "Import records from a JSON array of JRDs into the data file. Send SIGHUP to a\n" +
+			"running server afterwards, it saves its own records on shutdown.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dataFile, _ := cmd.Flags().GetString("data")
+			return importRecords(cmd, dataFile, args[0], conflict, dryRun)
+		},
+	}
+	importCmd.Flags().StringVar(&conflict, "conflict", "skip", "what to do with existing subjects: skip, overwrite or fail")
+	importCmd.Flags().BoolVar(&dryRun, "dry-run", false, "report what would change without writing")
+	recordCmd.AddCommand(importCmd)
+
+	return recordCmd
+}
+
+func importRecords(cmd *cobra.Command, dataFile, importFile, conflict string, dryRun bool) error {
+	switch conflict {
+	case "skip", "overwrite", "fail":
+	default:
+		return fmt.Errorf("--conflict must be skip, overwrite or fail, got %q", conflict)
+	}
+
+	store := db.NewData()
+	if err := store.LoadData(dataFile); err != nil {
+		return err
+	}
+
+	content, err := os.ReadFile(importFile)
+	if err != nil {
+		return err
+	}
+	var records []api.JRD
+	if err := json.Unmarshal(content, &records); err != nil {
+		return fmt.Errorf("decoding %s: %w", importFile, err)
+	}
+
+	var added, replaced, skipped int
+	for _, jrd := range records {
+		if jrd.Subject == "" {
+			return fmt.Errorf("record without subject in %s", importFile)
+		}
+		// Subjects are compared in canonical form, as they are looked up
+		if _, err := resource.GetSubject(jrd.Subject); err != nil {
+			return fmt.Errorf("record in %s: %w", importFile, err)
+		}
+		switch {
+		case !store.Has(jrd.Subject):
+			added++
+		case conflict == "overwrite":
+			replaced++
+		case conflict == "fail":
+			return fmt.Errorf("subject %q already exists, nothing was imported", jrd.Subject)
+		default:
+			skipped++
+			continue
+		}
+		store.Put(jrd)
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "%d added, %d replaced, %d skipped\n", added, replaced, skipped)
+	if dryRun {
+		return nil
+	}
+	return store.SaveData(dataFile)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/0dayfall/asdf/internal/api"
+	"github.com/0dayfall/asdf/internal/db"
+	"github.com/0dayfall/asdf/internal/resource"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+// writeRecords writes records as a JSON array to name in dir.
+func writeRecords(t *testing.T, dir, name string, records []api.JRD) string {
+	t.Helper()
+	content, err := json.Marshal(records)
+	require.NoError(t, err)
+	fileName := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(fileName, content, 0o644))
+	return fileName
+}
+
+func TestImportRecords(t *testing.T) {
+	existing := []api.JRD{{Subject: "acct:Alice@example.com", Aliases: []string{"https://example.com/old"}}}
+	// The first record differs from the existing one only in case and form
+	imported := []api.JRD{
+		{Subject: "alice@EXAMPLE.com", Aliases: []string{"https://example.com/new"}},
+		{Subject: "acct:bob@example.com"},
+	}
+	for _, test := range []struct {
+		name     string
+		conflict string
+		dryRun   bool
+		output   string
+		err      string
+		aliases  []string
+		records  int
+	}{
+		{
+			name: "skip", conflict: "skip", output: "1 added, 0 replaced, 1 skipped\n",
+			aliases: []string{"https://example.com/old"}, records: 2,
+		},
+		{
+			name: "overwrite", conflict: "overwrite", output: "1 added, 1 replaced, 0 skipped\n",
+			aliases: []string{"https://example.com/new"}, records: 2,
+		},
+		{
+			name: "fail", conflict: "fail", err: `subject "alice@EXAMPLE.com" already exists`,
+			aliases: []string{"https://example.com/old"}, records: 1,
+		},
+		{
+			name: "dry run", conflict: "overwrite", dryRun: true, output: "1 added, 1 replaced, 0 skipped\n",
+			aliases: []string{"https://example.com/old"}, records: 1,
+		},
+		{
+			name: "unknown conflict mode", conflict: "merge", err: "--conflict must be",
+			aliases: []string{"https://example.com/old"}, records: 1,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			dir := t.TempDir()
+			dataFile := writeRecords(t, dir, "data.json", existing)
+			importFile := writeRecords(t, dir, "import.json", imported)
+			var out bytes.Buffer
+			cmd := &cobra.Command{}
+			cmd.SetOut(&out)
+
+			// Act
+			err := importRecords(cmd, dataFile, importFile, test.conflict, test.dryRun)
+
+			// Assert
+			if test.err != "" {
+				require.ErrorContains(t, err, test.err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, test.output, out.String())
+			}
+			data := db.NewData()
+			require.NoError(t, data.LoadData(dataFile))
+			require.Equal(t, test.records, data.Len())
+			subject, err := resource.GetSubject("acct:alice@example.com")
+			require.NoError(t, err)
+			jrd, err := data.LookupResource(subject)
+			require.NoError(t, err)
+			require.NotNil(t, jrd)
+			require.Equal(t, test.aliases, jrd.Aliases)
+		})
+	}
+}
+
+func TestImportRecordsRejectsInvalidSubject(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	dataFile := writeRecords(t, dir, "data.json", nil)
+	importFile := writeRecords(t, dir, "import.json", []api.JRD{{Subject: "ftp://example.com/alice"}})
+
+	// Act
+	err := importRecords(&cobra.Command{}, dataFile, importFile, "skip", false)
+
+	// Assert
+	require.Error(t, err)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/0dayfall/asdf/internal/config"
+	"github.com/0dayfall/asdf/internal/logging"
+	"github.com/0dayfall/asdf/internal/server"
+	"github.com/spf13/cobra"
+)
+
+func serveCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "serve",
+		Short: "Serve WebFinger over HTTPS",
+		Args:  cobra.NoArgs,
+		RunE:  runServe,
+	}
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	logCloser, err := logging.Setup(cfg.LogFormat, cfg.LogLevel, cfg.LogOutput)
+	if err != nil {
+		return fmt.Errorf("setting up logging: %w", err)
+	}
+	defer logCloser.Close()
+
+	server.Start(cfg)
+	return nil
+}
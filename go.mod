@@ -1,17 +1,18 @@
-module asdf
+module github.com/0dayfall/asdf
 
-go 1.20
+go 1.22
 
 require (
-	github.com/pkg/errors v0.9.1
+	github.com/spf13/cobra v1.8.0
 	github.com/stretchr/testify v1.8.4
+	golang.org/x/net v0.25.0
+	golang.org/x/text v0.15.0
 )
 
-require github.com/gorilla/securecookie v1.1.2 // indirect
-
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/gorilla/sessions v1.2.2
+	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/spf13/pflag v1.0.5 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,160 @@
+// Package access applies access policies to the WebFinger endpoint, so known
+// peers can be served generously while scrapers are throttled or denied.
+package access
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/0dayfall/asdf/internal/apierror"
+	"github.com/0dayfall/asdf/internal/middleware"
+)
+
+// Policy selects requests by client address and user agent. A request
+// matches when it matches one of CIDRs and one of UserAgents, an empty list
+// matches any request.
+type Policy struct {
+	Name  string   `json:"name"`
+	CIDRs []string `json:"cidrs,omitempty"`
+	// UserAgents are matched as case-insensitive substrings
+	UserAgents []string `json:"user_agents,omitempty"`
+	// Deny answers matching requests with 403
+	Deny bool `json:"deny,omitempty"`
+	// RateLimit replaces the default limit for matching requests, in the
+	// format of middleware.ParseLimit
+	RateLimit string `json:"rate_limit,omitempty"`
+
+	prefixes []netip.Prefix
+	limiter  *middleware.RateLimiter
+}
+
+// Policies are tried in order, the first matching one applies. Requests
+// matching none get the default limit.
+type Policies struct {
+	policies []*Policy
+	ip       middleware.KeyFunc
+}
+
+// Load reads a JSON array of policies from fileName. ip returns the client
+// address of a request, it also keys the per-policy rate limits.
+func Load(fileName string, ip middleware.KeyFunc) (*Policies, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("asdf: reading access policies: %w", err)
+	}
+	var policies []*Policy
+	if err := json.Unmarshal(content, &policies); err != nil {
+		return nil, fmt.Errorf("asdf: decoding access policies: %w", err)
+	}
+	return New(policies, ip)
+}
+
+// New checks the policies and sets up their rate limiters.
+func New(policies []*Policy, ip middleware.KeyFunc) (*Policies, error) {
+	for i, policy := range policies {
+		if policy.Name == "" {
+			policy.Name = fmt.Sprintf("policy %d", i+1)
+		}
+		for _, cidr := range policy.CIDRs {
+			prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+			if err != nil {
+				return nil, fmt.Errorf("asdf: access policy %q: invalid CIDR %q", policy.Name, cidr)
+			}
+			policy.prefixes = append(policy.prefixes, prefix.Masked())
+		}
+		if policy.RateLimit != "" {
+			limit, err := middleware.ParseLimit(policy.RateLimit)
+			if err != nil {
+				return nil, fmt.Errorf("asdf: access policy %q: %w", policy.Name, err)
+			}
+			policy.limiter = middleware.NewRateLimiter(limit, ip)
+		}
+	}
+	return &Policies{policies: policies, ip: ip}, nil
+}
+
+func (policy *Policy) matches(addr netip.Addr, userAgent string) bool {
+	if len(policy.prefixes) > 0 {
+		found := false
+		for _, prefix := range policy.prefixes {
+			if prefix.Contains(addr) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(policy.UserAgents) > 0 {
+		userAgent = strings.ToLower(userAgent)
+		for _, agent := range policy.UserAgents {
+			if strings.Contains(userAgent, strings.ToLower(agent)) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// match returns the first policy matching r, or nil.
+func (p *Policies) match(r *http.Request) *Policy {
+	// An unparsable address only matches policies without CIDRs
+	addr, _ := netip.ParseAddr(p.ip(r))
+	addr = addr.Unmap()
+	for _, policy := range p.policies {
+		if policy.matches(addr, r.UserAgent()) {
+			return policy
+		}
+	}
+	return nil
+}
+
+// Handler applies the policies to requests for next. defaultLimiter, which
+// may be nil, limits requests that match no policy or one without its own
+// rate limit.
+func (p *Policies) Handler(defaultLimiter *middleware.RateLimiter, next http.Handler) http.Handler {
+	limited := next
+	if defaultLimiter != nil {
+		limited = defaultLimiter.Handler(next)
+	}
+	if p == nil {
+		return limited
+	}
+	limitedByPolicy := map[*Policy]http.Handler{}
+	for _, policy := range p.policies {
+		if policy.limiter != nil {
+			limitedByPolicy[policy] = policy.limiter.Handler(next)
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy := p.match(r)
+		switch {
+		case policy == nil:
+			limited.ServeHTTP(w, r)
+		case policy.Deny:
+			apierror.Write(w, apierror.Forbidden, "")
+		case policy.limiter != nil:
+			limitedByPolicy[policy].ServeHTTP(w, r)
+		default:
+			limited.ServeHTTP(w, r)
+		}
+	})
+}
+
+// Cleanup drops the refilled buckets of every policy's rate limiter, see
+// middleware.RateLimiter.Cleanup.
+func (p *Policies) Cleanup(ctx context.Context) error {
+	for _, policy := range p.policies {
+		if policy.limiter != nil {
+			policy.limiter.Cleanup(ctx)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package access
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/0dayfall/asdf/internal/middleware"
+	"github.com/stretchr/testify/require"
+)
+
+func remoteIP(r *http.Request) string {
+	return r.RemoteAddr
+}
+
+func TestHandler(t *testing.T) {
+	// Arrange
+	fileName := filepath.Join(t.TempDir(), "access.json")
+	require.NoError(t, os.WriteFile(fileName, []byte(`[
+		{"name": "scrapers", "cidrs": ["203.0.113.0/24"], "deny": true},
+		{"name": "mastodon", "user_agents": ["Mastodon/"], "rate_limit": "100/s"}
+	]`), 0o644))
+	policies, err := Load(fileName, remoteIP)
+	require.NoError(t, err)
+	defaultLimiter := middleware.NewRateLimiter(middleware.Limit{Requests: 1, Period: time.Hour}, remoteIP)
+	handler := policies.Handler(defaultLimiter, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(ip, userAgent string) int {
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/webfinger", nil)
+		r.RemoteAddr = ip
+		r.Header.Set("User-Agent", userAgent)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+		return rr.Code
+	}
+
+	// Act
+	denied := serve("203.0.113.7", "Mastodon/4.2")
+	peer := []int{serve("192.0.2.1", "http.rb/5.1 (Mastodon/4.2)"), serve("192.0.2.1", "http.rb/5.1 (Mastodon/4.2)")}
+	other := []int{serve("192.0.2.2", "curl/8.0"), serve("192.0.2.2", "curl/8.0")}
+
+	// Assert
+	require.Equal(t, http.StatusForbidden, denied)
+	require.Equal(t, []int{http.StatusOK, http.StatusOK}, peer)
+	require.Equal(t, []int{http.StatusOK, http.StatusTooManyRequests}, other)
+}
+
+func TestNewInvalid(t *testing.T) {
+	_, err := New([]*Policy{{Name: "bad", CIDRs: []string{"10.0.0.0/33"}}}, remoteIP)
+	require.ErrorContains(t, err, "bad")
+	_, err = New([]*Policy{{RateLimit: "often"}}, remoteIP)
+	require.ErrorContains(t, err, "policy 1")
+}
+
+func TestNilPolicies(t *testing.T) {
+	var policies *Policies
+	rr := httptest.NewRecorder()
+	policies.Handler(nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusNoContent, rr.Code)
+}
@@ -1,16 +1,31 @@
 package api
 
-// JRD represents a JSON Resource Descriptor
+import "time"
+
+// JRD represents a JSON Resource Descriptor. ExpiresAt optionally ends the
+// life of a record, such as one for a temporary service account or a deleted
+// account; it is kept in the data file but not served.
 type JRD struct {
 	Subject    string                 `json:"subject,omitempty"`
 	Aliases    []string               `json:"aliases,omitempty"`
 	Properties map[string]interface{} `json:"properties,omitempty"`
 	Links      []Link                 `json:"links,omitempty"`
+	ExpiresAt  *time.Time             `json:"expires_at,omitempty"`
+}
+
+// Expired reports whether the record has expired at now.
+func (jrd *JRD) Expired(now time.Time) bool {
+	return jrd.ExpiresAt != nil && !now.Before(*jrd.ExpiresAt)
 }
 
-// Link represents a link in the JRD
+// Link represents a link in the JRD. Template is the RFC 6415 URI template
+// used instead of Href by links such as OStatus subscribe; Titles maps
+// language tags to titles.
 type Link struct {
-	Rel  string `json:"rel,omitempty"`
-	Type string `json:"type,omitempty"`
-	Href string `json:"href,omitempty"`
+	Rel        string                 `json:"rel,omitempty"`
+	Type       string                 `json:"type,omitempty"`
+	Href       string                 `json:"href,omitempty"`
+	Template   string                 `json:"template,omitempty"`
+	Titles     map[string]string      `json:"titles,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
 }
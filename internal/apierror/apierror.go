@@ -0,0 +1,72 @@
+// Package apierror writes error responses as RFC 7807 problem details with a
+// machine-readable code, so clients do not have to parse messages.
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/0dayfall/asdf/internal/logging"
+)
+
+const ContentType = "application/problem+json"
+
+// Code identifies the kind of error independent of its message.
+type Code string
+
+const (
+	BadRequest      Code = "bad_request"
+	InvalidResource Code = "invalid_resource"
+	UserNotFound    Code = "user_not_found"
+	Forbidden       Code = "forbidden"
+	RateLimited     Code = "rate_limited"
+	Internal        Code = "internal_error"
+)
+
+var statuses = map[Code]int{
+	BadRequest:      http.StatusBadRequest,
+	InvalidResource: http.StatusBadRequest,
+	UserNotFound:    http.StatusNotFound,
+	Forbidden:       http.StatusForbidden,
+	RateLimited:     http.StatusTooManyRequests,
+	Internal:        http.StatusInternalServerError,
+}
+
+// Status returns the HTTP status answered for code.
+func Status(code Code) int {
+	if status, ok := statuses[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Problem is the RFC 7807 body. Code and RequestID are extension members.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Code      Code   `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Write answers with the problem for code. detail is shown to clients, so it
+// must not carry internal error messages. The request ID is taken from the
+// X-Request-ID response header set by middleware.RequestID.
+func Write(w http.ResponseWriter, code Code, detail string) {
+	status := Status(code)
+	problem := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Code:      code,
+		RequestID: w.Header().Get("X-Request-ID"),
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		logging.Errorf("Error writing problem %s: %v", code, err)
+	}
+}
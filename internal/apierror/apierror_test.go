@@ -0,0 +1,37 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	// Arrange
+	rr := httptest.NewRecorder()
+	rr.Header().Set("X-Request-ID", "abc")
+
+	// Act
+	Write(rr, InvalidResource, "missing resource")
+
+	// Assert
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Equal(t, ContentType, rr.Header().Get("Content-Type"))
+	var problem Problem
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+	require.Equal(t, Problem{
+		Type:      "about:blank",
+		Title:     "Bad Request",
+		Status:    http.StatusBadRequest,
+		Detail:    "missing resource",
+		Code:      InvalidResource,
+		RequestID: "abc",
+	}, problem)
+}
+
+func TestStatusUnknownCode(t *testing.T) {
+	require.Equal(t, http.StatusInternalServerError, Status("nonsense"))
+}
@@ -0,0 +1,129 @@
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Resolver finds the IP address of the client behind a request. Forwarded
+// and X-Forwarded-For are only honored when the connecting peer is a trusted
+// proxy, otherwise any client could pick its own address.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// NewResolver accepts trusted proxies as IP addresses or CIDR prefixes.
+func NewResolver(trustedProxies []string) (*Resolver, error) {
+	resolver := &Resolver{}
+	for _, proxy := range trustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(proxy)
+			if addrErr != nil {
+				return nil, fmt.Errorf("asdf: invalid trusted proxy %q", proxy)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		resolver.trusted = append(resolver.trusted, prefix.Masked())
+	}
+	return resolver, nil
+}
+
+// IP returns the client address of r. Forwarding headers are walked from the
+// nearest hop back, stopping at the first address that is not a trusted
+// proxy. RFC 7239 Forwarded takes precedence over X-Forwarded-For. Peers on a
+// Unix socket are always trusted: only the reverse proxy in front of the
+// socket is expected to connect there.
+func (res *Resolver) IP(r *http.Request) string {
+	peer := remoteAddr(r)
+	if !isUnixPeer(r) {
+		addr, err := netip.ParseAddr(peer)
+		if err != nil || !res.isTrusted(addr) {
+			return peer
+		}
+	}
+
+	hops := forwardedFor(r.Header.Values("Forwarded"))
+	if len(hops) == 0 {
+		hops = xForwardedFor(r.Header.Values("X-Forwarded-For"))
+	}
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			// Obfuscated or unknown identifiers end the trusted chain
+			break
+		}
+		client = hop.Unmap().String()
+		if !res.isTrusted(hop) {
+			break
+		}
+	}
+	return client
+}
+
+func (res *Resolver) isTrusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range res.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// isUnixPeer reports whether r came in over a Unix socket, whose peers have
+// no address: net/http sets RemoteAddr to "@" or leaves it empty.
+func isUnixPeer(r *http.Request) bool {
+	return r.RemoteAddr == "" || r.RemoteAddr == "@"
+}
+
+func remoteAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// forwardedFor extracts the for= parameters of RFC 7239 Forwarded headers
+// in order, without ports or brackets.
+func forwardedFor(headers []string) []string {
+	var hops []string
+	for _, header := range headers {
+		for _, element := range strings.Split(header, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(key, "for") {
+					continue
+				}
+				hops = append(hops, stripPort(strings.Trim(value, `"`)))
+			}
+		}
+	}
+	return hops
+}
+
+func xForwardedFor(headers []string) []string {
+	var hops []string
+	for _, header := range headers {
+		for _, hop := range strings.Split(header, ",") {
+			hops = append(hops, stripPort(strings.TrimSpace(hop)))
+		}
+	}
+	return hops
+}
+
+func stripPort(node string) string {
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	return strings.Trim(node, "[]")
+}
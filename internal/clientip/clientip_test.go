@@ -0,0 +1,54 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolverIP(t *testing.T) {
+	resolver, err := NewResolver([]string{"10.0.0.0/8", "192.0.2.10"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{"direct client", "198.51.100.7:4000", nil, "198.51.100.7"},
+		{"untrusted peer spoofing", "198.51.100.7:4000", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "198.51.100.7"},
+		{"trusted proxy", "10.0.0.1:4000", map[string]string{"X-Forwarded-For": "198.51.100.7"}, "198.51.100.7"},
+		{"spoofed hop before proxy", "10.0.0.1:4000", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.7, 10.0.0.2"}, "198.51.100.7"},
+		{"forwarded header", "192.0.2.10:4000", map[string]string{"Forwarded": `for="[2001:db8::1]:4711";proto=https, for=10.0.0.2`}, "2001:db8::1"},
+		{"forwarded over x-forwarded-for", "10.0.0.1:4000", map[string]string{"Forwarded": "for=198.51.100.7", "X-Forwarded-For": "1.2.3.4"}, "198.51.100.7"},
+		{"obfuscated hop", "10.0.0.1:4000", map[string]string{"Forwarded": "for=_hidden"}, "10.0.0.1"},
+		{"all hops trusted", "10.0.0.1:4000", map[string]string{"X-Forwarded-For": "10.0.0.3"}, "10.0.0.3"},
+		{"unix socket proxy", "@", map[string]string{"X-Forwarded-For": "198.51.100.7"}, "198.51.100.7"},
+		{"unnamed unix socket proxy", "", map[string]string{"Forwarded": "for=198.51.100.7"}, "198.51.100.7"},
+		{"unix socket hop chain", "@", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.7"}, "198.51.100.7"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.RemoteAddr = test.remoteAddr
+			for key, value := range test.headers {
+				request.Header.Set(key, value)
+			}
+
+			// Act
+			ip := resolver.IP(request)
+
+			// Assert
+			require.Equal(t, test.expected, ip)
+		})
+	}
+}
+
+func TestNewResolverRejectsInvalidProxy(t *testing.T) {
+	_, err := NewResolver([]string{"10.0.0.0/8", "proxy.example.com"})
+	require.Error(t, err)
+}
@@ -0,0 +1,278 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/0dayfall/asdf/internal/clientip"
+	"github.com/0dayfall/asdf/internal/jobs"
+	"github.com/0dayfall/asdf/internal/logging"
+	"github.com/0dayfall/asdf/internal/middleware"
+)
+
+var DefaultDataFile = path.Join("data", "data.json")
+
+// DefaultTombstoneRetention keeps expired records long enough for other
+// servers to see them gone on their next retries.
+const DefaultTombstoneRetention = 30 * 24 * time.Hour
+
+// DefaultPurgeInterval is how often records past their tombstone
+// retention are removed.
+const DefaultPurgeInterval = time.Hour
+
+// hexColor is what BRAND_COLOR accepts, it is written into a style element.
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// Config holds the server settings read from environment variables.
+type Config struct {
+	// Environment is "development" or "production"
+	Environment string
+	// DataFile is the JSON file the records are loaded from and saved to
+	DataFile string
+	// Addr is a TCP address or unix:///path/to.sock, from LISTEN or PORT
+	Addr     string
+	CertPath string
+	KeyPath  string
+	// CertDir optionally holds <hostname>.crt/.key pairs selected by SNI
+	CertDir string
+	// HTTPAddr optionally serves redirects to HTTPS and ACME challenges
+	HTTPAddr string
+	// ACMEChallengeDir is the webroot an ACME client writes challenges to
+	ACMEChallengeDir  string
+	LinkCheckInterval time.Duration
+	// PurgeInterval is how often records past TombstoneRetention are removed
+	PurgeInterval time.Duration
+	// TombstoneRetention is how long expired records answer 410 Gone
+	// before they are purged
+	TombstoneRetention time.Duration
+	// ServedDomains restricts lookups to these domains, empty serves all
+	ServedDomains []string
+	// DomainDefaultsFile maps domains to default aliases and links
+	DomainDefaultsFile string
+	// WebDir holds template and static directories overriding the embedded
+	// templates and assets file by file
+	WebDir string
+	// Branding of the HTML pages, empty for the defaults
+	BrandTitle   string
+	BrandLogoURL string
+	BrandColor   string
+	BrandFooter  string
+	// AccessPolicyFile holds a JSON array of access.Policy for the WebFinger
+	// endpoint
+	AccessPolicyFile string
+	// TrustedProxies may set the client IP through forwarding headers
+	TrustedProxies []string
+	// Per client IP limits, zero when unlimited
+	WebFingerRateLimit middleware.Limit
+	HTMLRateLimit      middleware.Limit
+	LogFormat          string
+	// LogLevel is "debug", "info", "warn" or "error"
+	LogLevel  string
+	LogOutput string
+	// AccessLogOutput enables the access log, it takes the same values as
+	// LogOutput
+	AccessLogOutput string
+	// AccessLogFormat is "combined" or "json"
+	AccessLogFormat string
+	// AccessLogSample is the fraction of successful requests logged
+	AccessLogSample float64
+	// SocketActivated is set when systemd passes the listening sockets to
+	// this process, LISTEN_PID names it
+	SocketActivated bool
+}
+
+// Load reads the configuration from the environment and validates it. Every
+// variable can instead be read from a file named by its _FILE variant. All
+// problems are reported together in the returned error.
+func Load() (*Config, error) {
+	var problems []error
+	getenv := func(name string) string {
+		value, err := lookupEnv(name)
+		if err != nil {
+			problems = append(problems, err)
+		}
+		return value
+	}
+
+	cfg := &Config{
+		Environment:        getenv("ENVIRONMENT"),
+		DataFile:           getenv("DATA_FILE"),
+		Addr:               getenv("LISTEN"),
+		CertPath:           getenv("SSL_CERT_PATH"),
+		KeyPath:            getenv("SSL_KEY_PATH"),
+		CertDir:            getenv("SSL_CERT_DIR"),
+		ACMEChallengeDir:   getenv("ACME_CHALLENGE_DIR"),
+		DomainDefaultsFile: getenv("DOMAIN_DEFAULTS_FILE"),
+		AccessPolicyFile:   getenv("ACCESS_POLICY_FILE"),
+		WebDir:             getenv("WEB_DIR"),
+		BrandTitle:         getenv("BRAND_TITLE"),
+		BrandLogoURL:       getenv("BRAND_LOGO_URL"),
+		BrandColor:         getenv("BRAND_COLOR"),
+		BrandFooter:        getenv("BRAND_FOOTER"),
+		LogFormat:          getenv("LOG_FORMAT"),
+		LogLevel:           getenv("LOG_LEVEL"),
+		LogOutput:          getenv("LOG_OUTPUT"),
+		AccessLogOutput:    getenv("ACCESS_LOG_OUTPUT"),
+		AccessLogFormat:    getenv("ACCESS_LOG_FORMAT"),
+		AccessLogSample:    1,
+		SocketActivated:    os.Getenv("LISTEN_FDS") != "" && os.Getenv("LISTEN_PID") == strconv.Itoa(os.Getpid()),
+	}
+	if cfg.Environment == "" {
+		cfg.Environment = "development"
+	}
+	if cfg.DataFile == "" {
+		cfg.DataFile = DefaultDataFile
+	}
+
+	if cfg.Addr == "" {
+		if port := getenv("PORT"); port != "" {
+			if err := validatePort(port); err != nil {
+				problems = append(problems, fmt.Errorf("PORT: %w", err))
+			}
+			cfg.Addr = ":" + port
+		}
+	}
+	if httpPort := getenv("HTTP_PORT"); httpPort != "" {
+		if err := validatePort(httpPort); err != nil {
+			problems = append(problems, fmt.Errorf("HTTP_PORT: %w", err))
+		}
+		cfg.HTTPAddr = ":" + httpPort
+	}
+	if interval := getenv("LINK_CHECK_INTERVAL"); interval != "" {
+		every, err := jobs.ParseSchedule(interval)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("LINK_CHECK_INTERVAL: %w", err))
+		}
+		cfg.LinkCheckInterval = every
+	}
+
+	if sample := getenv("ACCESS_LOG_SAMPLE"); sample != "" {
+		parsed, err := strconv.ParseFloat(sample, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			problems = append(problems, fmt.Errorf("ACCESS_LOG_SAMPLE: must be a number between 0 and 1, got %q", sample))
+		}
+		cfg.AccessLogSample = parsed
+	}
+
+	cfg.PurgeInterval = DefaultPurgeInterval
+	if interval := getenv("PURGE_INTERVAL"); interval != "" {
+		every, err := jobs.ParseSchedule(interval)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("PURGE_INTERVAL: %w", err))
+		}
+		cfg.PurgeInterval = every
+	}
+
+	cfg.TombstoneRetention = DefaultTombstoneRetention
+	if retention := getenv("TOMBSTONE_RETENTION"); retention != "" {
+		parsed, err := time.ParseDuration(retention)
+		if err != nil || parsed < 0 {
+			problems = append(problems, fmt.Errorf("TOMBSTONE_RETENTION: invalid duration %q", retention))
+		}
+		cfg.TombstoneRetention = parsed
+	}
+
+	if served := getenv("SERVED_DOMAINS"); served != "" {
+		cfg.ServedDomains = strings.Split(served, ",")
+	}
+	if proxies := getenv("TRUSTED_PROXIES"); proxies != "" {
+		cfg.TrustedProxies = strings.Split(proxies, ",")
+		if _, err := clientip.NewResolver(cfg.TrustedProxies); err != nil {
+			problems = append(problems, fmt.Errorf("TRUSTED_PROXIES: %w", err))
+		}
+	}
+	for name, limit := range map[string]*middleware.Limit{
+		"RATE_LIMIT_WEBFINGER": &cfg.WebFingerRateLimit,
+		"RATE_LIMIT_HTML":      &cfg.HTMLRateLimit,
+	} {
+		if spec := getenv(name); spec != "" {
+			parsed, err := middleware.ParseLimit(spec)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("%s: %w", name, err))
+			}
+			*limit = parsed
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		problems = append(problems, err)
+	}
+	return cfg, errors.Join(problems...)
+}
+
+// Validate checks required settings and the constraints between them.
+func (cfg *Config) Validate() error {
+	var problems []error
+	switch cfg.Environment {
+	case "development", "production":
+	default:
+		problems = append(problems, fmt.Errorf("ENVIRONMENT: must be development or production, got %q", cfg.Environment))
+	}
+	if cfg.Addr == "" && !cfg.SocketActivated {
+		problems = append(problems, errors.New("PORT or LISTEN must be set"))
+	}
+	// Unix sockets and sockets passed by systemd may sit behind a proxy
+	// terminating TLS, then plain HTTP is served without certificates
+	behindProxy := strings.HasPrefix(cfg.Addr, "unix://") || cfg.SocketActivated
+	if (cfg.CertPath == "") != (cfg.KeyPath == "") || (cfg.CertPath == "" && !behindProxy) {
+		problems = append(problems, errors.New("SSL_CERT_PATH and SSL_KEY_PATH must be set"))
+	}
+	if cfg.ACMEChallengeDir != "" && cfg.HTTPAddr == "" {
+		problems = append(problems, errors.New("ACME_CHALLENGE_DIR requires HTTP_PORT, challenges are served over HTTP"))
+	}
+	if cfg.BrandColor != "" && !hexColor.MatchString(cfg.BrandColor) {
+		problems = append(problems, fmt.Errorf("BRAND_COLOR: must be a hex color like #336699, got %q", cfg.BrandColor))
+	}
+	switch cfg.LogFormat {
+	case "", "text", "json":
+	default:
+		problems = append(problems, fmt.Errorf("LOG_FORMAT: must be text or json, got %q", cfg.LogFormat))
+	}
+	if _, err := logging.ParseLevel(cfg.LogLevel); err != nil {
+		problems = append(problems, fmt.Errorf("LOG_LEVEL: must be debug, info, warn or error, got %q", cfg.LogLevel))
+	}
+	switch cfg.AccessLogFormat {
+	case "", "combined", "json":
+	default:
+		problems = append(problems, fmt.Errorf("ACCESS_LOG_FORMAT: must be combined or json, got %q", cfg.AccessLogFormat))
+	}
+	return errors.Join(problems...)
+}
+
+// IsProduction reports whether failed startup checks should stop the server.
+func (cfg *Config) IsProduction() bool {
+	return cfg.Environment == "production"
+}
+
+// lookupEnv returns the variable name, or the contents of the file named by
+// name_FILE as used for Docker and Kubernetes secrets. Setting both is an
+// error since it is unclear which one is meant.
+func lookupEnv(name string) (string, error) {
+	value := os.Getenv(name)
+	fileName := os.Getenv(name + "_FILE")
+	if fileName == "" {
+		return value, nil
+	}
+	if value != "" {
+		return "", fmt.Errorf("%s and %s_FILE are both set", name, name)
+	}
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return "", fmt.Errorf("%s_FILE: %w", name, err)
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+func validatePort(port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	// Arrange
+	t.Setenv("PORT", "8443")
+	t.Setenv("HTTP_PORT", "8080")
+	t.Setenv("SSL_CERT_PATH", "server.crt")
+	t.Setenv("SSL_KEY_PATH", "server.key")
+	t.Setenv("LINK_CHECK_INTERVAL", "@hourly")
+	t.Setenv("PURGE_INTERVAL", "@every 10m")
+
+	// Act
+	cfg, err := Load()
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, "development", cfg.Environment)
+	require.Equal(t, ":8443", cfg.Addr)
+	require.Equal(t, ":8080", cfg.HTTPAddr)
+	require.Equal(t, time.Hour, cfg.LinkCheckInterval)
+	require.Equal(t, 10*time.Minute, cfg.PurgeInterval)
+	require.Equal(t, DefaultTombstoneRetention, cfg.TombstoneRetention)
+	require.False(t, cfg.IsProduction())
+}
+
+func TestLoadReportsAllProblems(t *testing.T) {
+	// Arrange
+	t.Setenv("ENVIRONMENT", "staging")
+	t.Setenv("PORT", "http")
+	t.Setenv("ACME_CHALLENGE_DIR", "/var/www/acme")
+	t.Setenv("LOG_FORMAT", "xml")
+	t.Setenv("LOG_LEVEL", "verbose")
+	t.Setenv("LINK_CHECK_INTERVAL", "often")
+	t.Setenv("PURGE_INTERVAL", "-1h")
+	t.Setenv("TOMBSTONE_RETENTION", "a month")
+	t.Setenv("ACCESS_LOG_FORMAT", "common")
+	t.Setenv("ACCESS_LOG_SAMPLE", "2")
+
+	// Act
+	_, err := Load()
+
+	// Assert
+	require.Error(t, err)
+	for _, problem := range []string{"PORT", "ENVIRONMENT", "SSL_CERT_PATH", "ACME_CHALLENGE_DIR", "LOG_FORMAT", "LOG_LEVEL", "LINK_CHECK_INTERVAL", "PURGE_INTERVAL", "TOMBSTONE_RETENTION", "ACCESS_LOG_FORMAT", "ACCESS_LOG_SAMPLE"} {
+		require.Contains(t, err.Error(), problem)
+	}
+}
+
+func TestLoadFromFile(t *testing.T) {
+	// Arrange
+	keyPathFile := filepath.Join(t.TempDir(), "ssl_key_path")
+	require.NoError(t, os.WriteFile(keyPathFile, []byte("/run/secrets/server.key\n"), 0o600))
+	t.Setenv("PORT", "8443")
+	t.Setenv("SSL_CERT_PATH", "server.crt")
+	t.Setenv("SSL_KEY_PATH_FILE", keyPathFile)
+
+	// Act
+	cfg, err := Load()
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, "/run/secrets/server.key", cfg.KeyPath)
+
+	t.Setenv("SSL_KEY_PATH", "server.key")
+	_, err = Load()
+	require.ErrorContains(t, err, "SSL_KEY_PATH and SSL_KEY_PATH_FILE are both set")
+}
+
+func TestLoadPlainHTTPBehindProxy(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		listen string
+		fds    string
+		pid    string
+		valid  bool
+	}{
+		{name: "unix socket", listen: "unix:///run/asdf.sock", valid: true},
+		{name: "socket activated", fds: "1", pid: strconv.Itoa(os.Getpid()), valid: true},
+		{name: "sockets of another process", listen: ":8443", fds: "1", pid: "1"},
+		{name: "tcp", listen: ":8443"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			t.Setenv("LISTEN", test.listen)
+			t.Setenv("LISTEN_FDS", test.fds)
+			t.Setenv("LISTEN_PID", test.pid)
+
+			// Act
+			cfg, err := Load()
+
+			// Assert
+			if test.valid {
+				require.NoError(t, err)
+				require.Empty(t, cfg.CertPath)
+			} else {
+				require.ErrorContains(t, err, "SSL_CERT_PATH")
+			}
+		})
+	}
+}
@@ -1,63 +1,201 @@
 package db
 
 import (
-	"asdf/internal/api"
-	"asdf/internal/resource"
+	"context"
 	"encoding/json"
 	"errors"
 	"log"
 	"os"
+	"sync"
+	"time"
+
+	"github.com/0dayfall/asdf/internal/api"
+	"github.com/0dayfall/asdf/internal/logging"
+	"github.com/0dayfall/asdf/internal/resource"
 )
 
+// Store is the read side of a WebFinger record backend. Data, backed by a
+// JSON file, is the only implementation so far.
+type Store interface {
+	LookupResource(subject string) (*api.JRD, error)
+	Records() []api.JRD
+}
+
 type Data struct {
+	// TombstoneRetention is how long expired records are kept, answered
+	// with 410 Gone, before PurgeExpired removes them
+	TombstoneRetention time.Duration
+
+	mu   sync.RWMutex
 	data []api.JRD
+	// subjects and aliases map the canonical form of each subject and alias,
+	// see resource.GetSubject, to the index of its record
+	subjects map[string]int
+	aliases  map[string]int
 }
 
 func NewData() *Data {
 	return &Data{}
 }
 
+// LoadData replaces the records with the ones in fileName. On error the
+// current records are kept, so it is safe to call again to reload.
 func (app *Data) LoadData(fileName string) error {
 	dir, _ := os.Getwd()
 	file, err := os.Open(fileName)
 	if err != nil {
-		log.Printf("Error opening file: %s", err.Error()+dir)
+		logging.Errorf("Error opening file: %s", err.Error()+dir)
 		return errors.New("Error loading file")
 	}
 	defer file.Close()
 
+	var data []api.JRD
 	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&app.data); err != nil {
-		log.Printf("Error decoding JSON: %v", err)
+	if err := decoder.Decode(&data); err != nil {
+		logging.Errorf("Error decoding JSON: %v", err)
 		return errors.New("Error decoding JSON")
 	}
+
+	app.mu.Lock()
+	app.data = data
+	app.reindex()
+	app.mu.Unlock()
 	return nil
 }
 
+// reindex rebuilds the subject and alias indexes, the caller holds the
+// write lock. When records share a subject or alias the first one wins, and
+// records with an invalid subject can't be looked up.
+func (app *Data) reindex() {
+	app.subjects = map[string]int{}
+	app.aliases = map[string]int{}
+	for i, jrd := range app.data {
+		subject, err := resource.GetSubject(jrd.Subject)
+		if err != nil {
+			logging.Warnf("Skipping record %q: %v", jrd.Subject, err)
+			continue
+		}
+		if _, taken := app.subjects[subject]; !taken {
+			app.subjects[subject] = i
+		}
+		for _, alias := range jrd.Aliases {
+			key, err := resource.GetSubject(alias)
+			if err != nil {
+				continue
+			}
+			if _, taken := app.aliases[key]; !taken {
+				app.aliases[key] = i
+			}
+		}
+	}
+}
+
+// Len returns the number of records currently held.
+func (app *Data) Len() int {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+	return len(app.data)
+}
+
+// Records returns a copy of all records.
+func (app *Data) Records() []api.JRD {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+	records := make([]api.JRD, len(app.data))
+	copy(records, app.data)
+	return records
+}
+
+// LookupResource returns the record whose subject, or else one of whose
+// aliases, has the canonical form subject. It returns nil if there is none.
 func (app *Data) LookupResource(subject string) (*api.JRD, error) {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+	i, ok := app.subjects[subject]
+	if !ok {
+		// Not a subject, but it may be an alias of a record
+		i, ok = app.aliases[subject]
+	}
+	if !ok {
+		return nil, nil
+	}
+	jrd := app.data[i]
+	return &jrd, nil
+}
+
+// Put adds jrd, replacing the record with the same canonical subject if
+// there is one. It reports whether a record was replaced.
+func (app *Data) Put(jrd api.JRD) bool {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	defer app.reindex()
+	if i, ok := app.index(jrd.Subject); ok {
+		app.data[i] = jrd
+		return true
+	}
+	app.data = append(app.data, jrd)
+	return false
+}
+
+// PurgeExpired removes the records that expired more than TombstoneRetention
+// ago, for the job runner.
+func (app *Data) PurgeExpired(ctx context.Context) error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	now := time.Now().Add(-app.TombstoneRetention)
+	kept := app.data[:0]
 	for _, jrd := range app.data {
-		acct, err := resource.GetSubject(jrd.Subject)
-		if err != nil {
-			return nil, err
+		if !jrd.Expired(now) {
+			kept = append(kept, jrd)
+		}
+	}
+	if purged := len(app.data) - len(kept); purged > 0 {
+		// Clear the tail so the removed records can be collected
+		clear(app.data[len(kept):])
+		app.data = kept
+		app.reindex()
+		log.Printf("Purged %d expired records", purged)
+	}
+	return nil
+}
+
+// Has reports whether there is a record with the same canonical subject.
+func (app *Data) Has(subject string) bool {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+	_, ok := app.index(subject)
+	return ok
+}
+
+// index returns the index of the record with the canonical form of subject,
+// or of one with exactly subject if it has none. The caller holds the lock.
+func (app *Data) index(subject string) (int, bool) {
+	if canonical, err := resource.GetSubject(subject); err == nil {
+		if i, ok := app.subjects[canonical]; ok {
+			return i, true
 		}
-		if acct == subject {
-			return &jrd, nil
+	}
+	for i, jrd := range app.data {
+		if jrd.Subject == subject {
+			return i, true
 		}
 	}
-	return nil, nil
+	return 0, false
 }
 
 func (app *Data) SaveData(fileName string) error {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
 	file, err := os.Create(fileName)
 	if err != nil {
-		log.Printf("Error creating file: %v", err)
+		logging.Errorf("Error creating file: %v", err)
 		return errors.New("Error creating file")
 	}
 	defer file.Close()
 
 	encoder := json.NewEncoder(file)
 	if err := encoder.Encode(app.data); err != nil {
-		log.Printf("Error encoding JSON: %v", err)
+		logging.Errorf("Error encoding JSON: %v", err)
 		return errors.New("Error encoding JSON")
 	}
 	return nil
@@ -0,0 +1,143 @@
+package db
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/0dayfall/asdf/internal/api"
+	"github.com/0dayfall/asdf/internal/resource"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveAndLoadRoundTripsLinks(t *testing.T) {
+	// Arrange
+	data := NewData()
+	err := data.LoadData(path.Join("..", "rest", "test", "data.json"))
+	require.NoError(t, err)
+	fileName := filepath.Join(t.TempDir(), "data.json")
+
+	// Act
+	err = data.SaveData(fileName)
+	require.NoError(t, err)
+	reloaded := NewData()
+	err = reloaded.LoadData(fileName)
+	require.NoError(t, err)
+
+	// Assert
+	require.Equal(t, data.Records(), reloaded.Records())
+	jrd, err := reloaded.LookupResource("templated@example.com")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"en-us": "Follow", "und": "Follow"}, jrd.Links[0].Titles)
+	require.Equal(t, map[string]interface{}{
+		"http://example.com/prop/kind":  "subscribe",
+		"http://example.com/prop/unset": nil,
+	}, jrd.Links[0].Properties)
+}
+
+func TestLookupResourceByAlias(t *testing.T) {
+	// Arrange
+	data := NewData()
+	data.Put(api.JRD{Subject: "acct:alice@example.com", Aliases: []string{"acct:al@example.com", "https://example.com/@alice"}})
+
+	// Act
+	byAcctAlias, err := data.LookupResource("al@example.com")
+	require.NoError(t, err)
+	byURLAlias, err := data.LookupResource("https://example.com/@alice")
+	require.NoError(t, err)
+	missing, err := data.LookupResource("bob@example.com")
+	require.NoError(t, err)
+
+	// Assert
+	require.Equal(t, "acct:alice@example.com", byAcctAlias.Subject)
+	require.Equal(t, "acct:alice@example.com", byURLAlias.Subject)
+	require.Nil(t, missing)
+}
+
+func TestLookupResourceInternationalized(t *testing.T) {
+	// Arrange
+	data := NewData()
+	data.Put(api.JRD{Subject: "acct:josé@bücher.example"})
+
+	// Act
+	byPunycode, err := data.LookupResource("jos\u00e9@xn--bcher-kva.example")
+	require.NoError(t, err)
+
+	// Assert
+	require.NotNil(t, byPunycode)
+	require.Equal(t, "acct:josé@bücher.example", byPunycode.Subject)
+}
+
+func TestLookupResourceIgnoresCase(t *testing.T) {
+	// Arrange
+	data := NewData()
+	data.Put(api.JRD{Subject: "acct:Alice@example.com"})
+
+	// Act
+	subject, err := resource.GetSubject("acct:ALICE@Example.COM")
+	require.NoError(t, err)
+	jrd, err := data.LookupResource(subject)
+	require.NoError(t, err)
+
+	// Assert
+	require.NotNil(t, jrd)
+	require.Equal(t, "acct:Alice@example.com", jrd.Subject)
+}
+
+func TestPutReplacesCanonicalSubject(t *testing.T) {
+	// Arrange
+	data := NewData()
+	data.Put(api.JRD{Subject: "acct:Alice@example.com"})
+
+	// Act
+	replaced := data.Put(api.JRD{Subject: "alice@EXAMPLE.com", Aliases: []string{"https://example.com/@alice"}})
+
+	// Assert
+	require.True(t, replaced)
+	require.True(t, data.Has("acct:alice@example.com"))
+	require.Equal(t, 1, data.Len())
+	require.Equal(t, []string{"https://example.com/@alice"}, data.Records()[0].Aliases)
+}
+
+func TestPurgeExpired(t *testing.T) {
+	// Arrange
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	data := NewData()
+	data.Put(api.JRD{Subject: "acct:temp@example.com", ExpiresAt: &past})
+	data.Put(api.JRD{Subject: "acct:later@example.com", ExpiresAt: &future})
+	data.Put(api.JRD{Subject: "acct:alice@example.com"})
+
+	// Act
+	err := data.PurgeExpired(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, 2, data.Len())
+	expired, err := data.LookupResource("temp@example.com")
+	require.NoError(t, err)
+	require.Nil(t, expired)
+	later, err := data.LookupResource("later@example.com")
+	require.NoError(t, err)
+	require.NotNil(t, later)
+}
+
+func TestPurgeExpiredKeepsTombstones(t *testing.T) {
+	// Arrange
+	recent := time.Now().Add(-time.Minute)
+	old := time.Now().Add(-2 * time.Hour)
+	data := NewData()
+	data.TombstoneRetention = time.Hour
+	data.Put(api.JRD{Subject: "acct:recent@example.com", ExpiresAt: &recent})
+	data.Put(api.JRD{Subject: "acct:old@example.com", ExpiresAt: &old})
+
+	// Act
+	err := data.PurgeExpired(context.Background())
+
+	// Assert
+	require.NoError(t, err)
+	require.True(t, data.Has("acct:recent@example.com"))
+	require.False(t, data.Has("acct:old@example.com"))
+}
@@ -0,0 +1,153 @@
+package domains
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/0dayfall/asdf/internal/api"
+	"github.com/0dayfall/asdf/internal/resource"
+)
+
+// Defaults are added to every record of a domain. In aliases, hrefs and link
+// templates {user}, {domain} and {subject} are replaced by the parts of the
+// record's acct subject.
+type Defaults struct {
+	Aliases []string   `json:"aliases,omitempty"`
+	Links   []api.Link `json:"links,omitempty"`
+}
+
+// Registry knows the domains served by this server and their defaults.
+type Registry struct {
+	served map[string]Defaults
+}
+
+// NewRegistry builds a registry from a list of domain names and an optional
+// JSON file mapping domain names to Defaults. Its domains are served too.
+// With neither, every domain is served.
+func NewRegistry(served []string, defaultsFile string) (*Registry, error) {
+	registry := &Registry{served: map[string]Defaults{}}
+	for _, domain := range served {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			registry.served[canonicalDomain(domain)] = Defaults{}
+		}
+	}
+	if defaultsFile == "" {
+		return registry, nil
+	}
+
+	content, err := os.ReadFile(defaultsFile)
+	if err != nil {
+		return nil, fmt.Errorf("asdf: reading domain defaults: %w", err)
+	}
+	var defaults map[string]Defaults
+	if err := json.Unmarshal(content, &defaults); err != nil {
+		return nil, fmt.Errorf("asdf: decoding domain defaults: %w", err)
+	}
+	for domain, d := range defaults {
+		registry.served[canonicalDomain(domain)] = d
+	}
+	return registry, nil
+}
+
+// canonicalDomain returns the ASCII form of domain that canonical subjects
+// carry, see resource.CanonicalHost.
+func canonicalDomain(domain string) string {
+	if host, ok := resource.CanonicalHost(domain); ok {
+		return host
+	}
+	return strings.ToLower(domain)
+}
+
+// Domain returns the host part of an acct subject such as user@example.com.
+func Domain(subject string) string {
+	at := strings.LastIndex(subject, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(subject[at+1:])
+}
+
+// Serves reports whether lookups for accounts at domain are answered.
+func (reg *Registry) Serves(domain string) bool {
+	if reg == nil || len(reg.served) == 0 {
+		return true
+	}
+	_, ok := reg.served[canonicalDomain(domain)]
+	return ok
+}
+
+// Apply returns a copy of jrd with the defaults of its domain added. Aliases
+// already present and links with a rel already present are kept as they are.
+// Records whose subject is not an acct: URI or a bare user@host, such as
+// mailto: and https: subjects, are returned unchanged.
+func (reg *Registry) Apply(jrd *api.JRD) *api.JRD {
+	if reg == nil || jrd == nil {
+		return jrd
+	}
+	user, domain, ok := account(jrd.Subject)
+	if !ok {
+		return jrd
+	}
+	defaults, ok := reg.served[domain]
+	if !ok || (len(defaults.Aliases) == 0 && len(defaults.Links) == 0) {
+		return jrd
+	}
+
+	expand := strings.NewReplacer("{user}", user, "{domain}", domain, "{subject}", jrd.Subject).Replace
+
+	result := *jrd
+	result.Aliases = append([]string(nil), jrd.Aliases...)
+	result.Links = append([]api.Link(nil), jrd.Links...)
+	for _, alias := range defaults.Aliases {
+		alias = expand(alias)
+		if !contains(result.Aliases, alias) {
+			result.Aliases = append(result.Aliases, alias)
+		}
+	}
+	for _, link := range defaults.Links {
+		if hasRel(result.Links, link.Rel) {
+			continue
+		}
+		link.Href = expand(link.Href)
+		link.Template = expand(link.Template)
+		result.Links = append(result.Links, link)
+	}
+	return &result
+}
+
+// account splits an acct: or bare user@host subject into its user part, as
+// written, and its canonical domain, see resource.GetSubject.
+func account(subject string) (user, domain string, ok bool) {
+	scheme, rest, hasScheme := strings.Cut(subject, ":")
+	switch {
+	case !hasScheme || strings.Contains(scheme, "@"):
+		rest = subject
+	case !strings.EqualFold(scheme, "acct"):
+		return "", "", false
+	}
+	canonical, err := resource.GetSubject(subject)
+	if err != nil {
+		return "", "", false
+	}
+	return rest[:strings.LastIndex(rest, "@")], Domain(canonical), true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func hasRel(links []api.Link, rel string) bool {
+	for _, link := range links {
+		if link.Rel == rel {
+			return true
+		}
+	}
+	return false
+}
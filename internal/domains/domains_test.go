@@ -0,0 +1,84 @@
+package domains
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/0dayfall/asdf/internal/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	// Arrange
+	defaultsFile := filepath.Join(t.TempDir(), "domains.json")
+	defaults := `{"Example.org": {
+		"aliases": ["https://example.org/@{user}"],
+		"links": [{"rel": "http://webfinger.net/rel/profile-page", "type": "text/html", "href": "https://example.org/@{user}"}]
+	}}`
+	require.NoError(t, os.WriteFile(defaultsFile, []byte(defaults), 0o600))
+	registry, err := NewRegistry([]string{"example.com"}, defaultsFile)
+	require.NoError(t, err)
+
+	// Act
+	jrd := registry.Apply(&api.JRD{
+		Subject: "acct:alice@example.org",
+		Links:   []api.Link{{Rel: "self", Href: "https://example.org/users/alice"}},
+	})
+
+	// Assert
+	require.True(t, registry.Serves("example.com"))
+	require.True(t, registry.Serves("EXAMPLE.ORG"))
+	require.False(t, registry.Serves("example.net"))
+	require.Equal(t, []string{"https://example.org/@alice"}, jrd.Aliases)
+	require.Equal(t, []api.Link{
+		{Rel: "self", Href: "https://example.org/users/alice"},
+		{Rel: "http://webfinger.net/rel/profile-page", Type: "text/html", Href: "https://example.org/@alice"},
+	}, jrd.Links)
+}
+
+func TestApplyIgnoresSubjectsWithoutAccount(t *testing.T) {
+	// Arrange
+	defaultsFile := filepath.Join(t.TempDir(), "domains.json")
+	defaults := `{"": {"aliases": ["https://example.org/@{user}"]}, "example.org": {"aliases": ["https://example.org/@{user}"]}}`
+	require.NoError(t, os.WriteFile(defaultsFile, []byte(defaults), 0o600))
+	registry, err := NewRegistry(nil, defaultsFile)
+	require.NoError(t, err)
+
+	for _, subject := range []string{"https://example.org/alice", "mailto:bob@example.org", "acct:bob"} {
+		jrd := &api.JRD{Subject: subject}
+
+		// Act
+		applied := registry.Apply(jrd)
+
+		// Assert
+		require.Same(t, jrd, applied, subject)
+	}
+}
+
+func TestApplyToBareAccount(t *testing.T) {
+	// Arrange
+	defaultsFile := filepath.Join(t.TempDir(), "domains.json")
+	require.NoError(t, os.WriteFile(defaultsFile, []byte(`{"bücher.example": {"aliases": ["https://{domain}/@{user}"]}}`), 0o600))
+	registry, err := NewRegistry(nil, defaultsFile)
+	require.NoError(t, err)
+
+	// Act
+	jrd := registry.Apply(&api.JRD{Subject: "Bob@BÜCHER.example"})
+
+	// Assert
+	require.Equal(t, []string{"https://xn--bcher-kva.example/@Bob"}, jrd.Aliases)
+}
+
+func TestEmptyRegistryServesEverything(t *testing.T) {
+	registry, err := NewRegistry(nil, "")
+	require.NoError(t, err)
+	require.True(t, registry.Serves("example.net"))
+}
+
+func TestRegistryInternationalizedDomain(t *testing.T) {
+	registry, err := NewRegistry([]string{"bücher.example"}, "")
+	require.NoError(t, err)
+	require.True(t, registry.Serves("xn--bcher-kva.example"))
+	require.True(t, registry.Serves("BÜCHER.example"))
+}
@@ -0,0 +1,95 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/0dayfall/asdf/internal/logging"
+)
+
+// Check reports whether a dependency is usable. It should honor ctx.
+type Check func(ctx context.Context) error
+
+type checkResult struct {
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	// Error is always "unavailable" for a failed check, the error itself is
+	// logged since the probes are not authenticated
+	Error string `json:"error,omitempty"`
+}
+
+type report struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+// Handler serves liveness and readiness probes.
+type Handler struct {
+	Timeout time.Duration
+	checks  map[string]Check
+}
+
+func NewHandler(timeout time.Duration) *Handler {
+	return &Handler{Timeout: timeout, checks: map[string]Check{}}
+}
+
+// Add registers a readiness check under name.
+func (h *Handler) Add(name string, check Check) {
+	h.checks[name] = check
+}
+
+// Router is the part of *http.ServeMux that RegisterRoutes needs.
+type Router interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+func (h *Handler) RegisterRoutes(mux Router) {
+	mux.Handle("GET /health/live", http.HandlerFunc(h.Live))
+	mux.Handle("GET /health/ready", http.HandlerFunc(h.Ready))
+}
+
+// Live reports that the process is up and serving.
+func (h *Handler) Live(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, http.StatusOK, report{Status: "ok"})
+}
+
+// Ready runs every check and answers 503 if any of them fails.
+func (h *Handler) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.Timeout)
+	defer cancel()
+
+	names := make([]string, 0, len(h.checks))
+	for name := range h.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := report{Status: "ok", Checks: map[string]checkResult{}}
+	code := http.StatusOK
+	for _, name := range names {
+		started := time.Now()
+		err := h.checks[name](ctx)
+		check := checkResult{Status: "ok", Latency: time.Since(started).String()}
+		if err != nil {
+			logging.Errorf("Health check %s failed: %v", name, err)
+			check.Status = "unavailable"
+			check.Error = "unavailable"
+			result.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+		}
+		result.Checks[name] = check
+	}
+	writeReport(w, code, result)
+}
+
+func writeReport(w http.ResponseWriter, code int, result report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		logging.Errorf("Error writing health report: %v", err)
+	}
+}
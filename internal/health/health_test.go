@@ -0,0 +1,64 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReady(t *testing.T) {
+	tests := []struct {
+		name     string
+		storeErr error
+		code     int
+		status   string
+	}{
+		{"all ok", nil, http.StatusOK, "ok"},
+		{"store down", errors.New("stat /srv/asdf/data.json: no such file"), http.StatusServiceUnavailable, "unavailable"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			handler := NewHandler(time.Second)
+			handler.Add("templates", func(ctx context.Context) error { return nil })
+			handler.Add("store", func(ctx context.Context) error { return test.storeErr })
+			mux := http.NewServeMux()
+			handler.RegisterRoutes(mux)
+			rr := httptest.NewRecorder()
+
+			// Act
+			mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+
+			// Assert
+			require.Equal(t, test.code, rr.Code)
+			require.NotContains(t, rr.Body.String(), "/srv/asdf")
+			var result report
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&result))
+			require.Equal(t, test.status, result.Status)
+			require.Equal(t, test.status, result.Checks["store"].Status)
+			if test.storeErr != nil {
+				require.Equal(t, "unavailable", result.Checks["store"].Error)
+			}
+			require.Equal(t, "ok", result.Checks["templates"].Status)
+		})
+	}
+}
+
+func TestLive(t *testing.T) {
+	// Arrange
+	handler := NewHandler(time.Second)
+	handler.Add("store", func(ctx context.Context) error { return errors.New("down") })
+	rr := httptest.NewRecorder()
+
+	// Act
+	handler.Live(rr, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+
+	// Assert
+	require.Equal(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,179 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0dayfall/asdf/internal/logging"
+	"github.com/0dayfall/asdf/internal/metrics"
+)
+
+// Func is the work of a job. A returned error is logged and counted, the job
+// keeps its schedule.
+type Func func(ctx context.Context) error
+
+// Stats describes the runs of a job so far.
+type Stats struct {
+	Runs         int
+	Failures     int
+	LastRun      time.Time
+	LastDuration time.Duration
+	LastError    string
+}
+
+type job struct {
+	name  string
+	every time.Duration
+	run   Func
+}
+
+// Runner runs registered jobs periodically until stopped.
+type Runner struct {
+	mu     sync.Mutex
+	jobs   []job
+	stats  map[string]*Stats
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewRunner() *Runner {
+	return &Runner{stats: map[string]*Stats{}}
+}
+
+// ParseSchedule accepts a Go duration ("15m"), "@every <duration>",
+// "@hourly" or "@daily".
+func ParseSchedule(spec string) (time.Duration, error) {
+	switch spec {
+	case "@hourly":
+		return time.Hour, nil
+	case "@daily":
+		return 24 * time.Hour, nil
+	}
+	every, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every")))
+	if err != nil {
+		return 0, fmt.Errorf("asdf: invalid schedule %q", spec)
+	}
+	if every <= 0 {
+		return 0, fmt.Errorf("asdf: schedule %q must be positive", spec)
+	}
+	return every, nil
+}
+
+// Register adds a job running every interval. Jobs registered after Start
+// are not run.
+func (r *Runner) Register(name string, every time.Duration, run Func) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs = append(r.jobs, job{name: name, every: every, run: run})
+	r.stats[name] = &Stats{}
+}
+
+// Start runs every registered job in its own goroutine. The first run of a
+// job and the pause between runs are spread by up to a tenth of its interval
+// so jobs of several instances don't fire in lockstep.
+func (r *Runner) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, j := range r.jobs {
+		r.wg.Add(1)
+		go r.loop(ctx, j)
+	}
+}
+
+// Stop cancels the jobs and waits for running ones to return.
+func (r *Runner) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+}
+
+// Stats returns a snapshot of the stats of every job.
+func (r *Runner) Stats() map[string]Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := make(map[string]Stats, len(r.stats))
+	for name, s := range r.stats {
+		stats[name] = *s
+	}
+	return stats
+}
+
+// Metrics returns the stats of every job as metrics, for metrics.Handler.
+// Errors are left out, they may hold details not meant for scrapers.
+func (r *Runner) Metrics() []metrics.Metric {
+	stats := r.Stats()
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	runs := metrics.Metric{Name: "asdf_job_runs_total", Help: "Runs of the job.", Type: metrics.Counter}
+	failures := metrics.Metric{Name: "asdf_job_failures_total", Help: "Runs of the job that returned an error.", Type: metrics.Counter}
+	lastRun := metrics.Metric{Name: "asdf_job_last_run_timestamp_seconds", Help: "Start of the last run of the job.", Type: metrics.Gauge}
+	lastDuration := metrics.Metric{Name: "asdf_job_last_duration_seconds", Help: "Duration of the last run of the job.", Type: metrics.Gauge}
+	for _, name := range names {
+		job := stats[name]
+		labels := map[string]string{"job": name}
+		runs.Samples = append(runs.Samples, metrics.Sample{Labels: labels, Value: float64(job.Runs)})
+		failures.Samples = append(failures.Samples, metrics.Sample{Labels: labels, Value: float64(job.Failures)})
+		var started float64
+		if !job.LastRun.IsZero() {
+			started = float64(job.LastRun.UnixNano()) / 1e9
+		}
+		lastRun.Samples = append(lastRun.Samples, metrics.Sample{Labels: labels, Value: started})
+		lastDuration.Samples = append(lastDuration.Samples, metrics.Sample{Labels: labels, Value: job.LastDuration.Seconds()})
+	}
+	return []metrics.Metric{runs, failures, lastRun, lastDuration}
+}
+
+func (r *Runner) loop(ctx context.Context, j job) {
+	defer r.wg.Done()
+	timer := time.NewTimer(jitter(j.every))
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		r.runOnce(ctx, j)
+		timer.Reset(j.every + jitter(j.every))
+	}
+}
+
+func (r *Runner) runOnce(ctx context.Context, j job) {
+	started := time.Now()
+	err := j.run(ctx)
+	duration := time.Since(started)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := r.stats[j.name]
+	stats.Runs++
+	stats.LastRun = started
+	stats.LastDuration = duration
+	stats.LastError = ""
+	if err != nil {
+		stats.Failures++
+		stats.LastError = err.Error()
+		logging.Errorf("Job %s failed after %s: %v", j.name, duration, err)
+		return
+	}
+	logging.Debugf("Job %s ran in %s", j.name, duration)
+}
+
+func jitter(every time.Duration) time.Duration {
+	max := int64(every / 10)
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(max))
+}
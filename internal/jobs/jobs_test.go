@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected time.Duration
+	}{
+		{"15m", 15 * time.Minute},
+		{"@every 30s", 30 * time.Second},
+		{"@hourly", time.Hour},
+		{"@daily", 24 * time.Hour},
+	}
+	for _, test := range tests {
+		every, err := ParseSchedule(test.spec)
+		require.NoError(t, err, test.spec)
+		require.Equal(t, test.expected, every, test.spec)
+	}
+
+	_, err := ParseSchedule("* * * * *")
+	require.Error(t, err)
+	_, err = ParseSchedule("-1m")
+	require.Error(t, err)
+}
+
+func TestRunnerRunsAndStops(t *testing.T) {
+	// Arrange
+	runner := NewRunner()
+	ran := make(chan struct{}, 10)
+	runner.Register("failing", time.Millisecond, func(ctx context.Context) error {
+		ran <- struct{}{}
+		return errors.New("boom")
+	})
+
+	// Act
+	runner.Start(context.Background())
+	<-ran
+	<-ran
+	runner.Stop()
+
+	// Assert
+	stats := runner.Stats()["failing"]
+	require.GreaterOrEqual(t, stats.Runs, 2)
+	require.Equal(t, stats.Runs, stats.Failures)
+	require.Equal(t, "boom", stats.LastError)
+}
+
+func TestRunnerMetrics(t *testing.T) {
+	// Arrange
+	runner := NewRunner()
+	runner.Register("purge", time.Hour, func(ctx context.Context) error { return nil })
+	runner.Register("check", time.Hour, func(ctx context.Context) error { return errors.New("/secret/path") })
+	for _, j := range runner.jobs {
+		runner.runOnce(context.Background(), j)
+	}
+
+	// Act
+	metrics := runner.Metrics()
+
+	// Assert
+	require.Len(t, metrics, 4)
+	require.Equal(t, "asdf_job_runs_total", metrics[0].Name)
+	require.Equal(t, map[string]string{"job": "check"}, metrics[0].Samples[0].Labels)
+	require.Equal(t, float64(1), metrics[0].Samples[1].Value)
+	require.Equal(t, "asdf_job_failures_total", metrics[1].Name)
+	require.Equal(t, float64(1), metrics[1].Samples[0].Value)
+	require.Equal(t, float64(0), metrics[1].Samples[1].Value)
+	require.Positive(t, metrics[2].Samples[0].Value)
+	require.NotContains(t, fmt.Sprint(metrics), "secret")
+}
@@ -0,0 +1,146 @@
+package linkcheck
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0dayfall/asdf/internal/api"
+	"github.com/0dayfall/asdf/internal/metrics"
+)
+
+// Source provides the records whose links are checked.
+type Source interface {
+	Records() []api.JRD
+}
+
+// Status is the result of the last check of a link target.
+type Status struct {
+	OK        bool
+	Error     string
+	CheckedAt time.Time
+}
+
+// Checker sends HEAD requests to the http and https href of every link and
+// remembers which targets are broken. It is run periodically by the job
+// runner.
+type Checker struct {
+	Source Source
+	Client *http.Client
+
+	mu       sync.RWMutex
+	statuses map[string]Status
+	// broken counts the broken targets of the last check
+	broken int
+}
+
+func NewChecker(source Source) *Checker {
+	return &Checker{
+		Source:   source,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		statuses: map[string]Status{},
+	}
+}
+
+// CheckAll checks every distinct href once.
+func (c *Checker) CheckAll(ctx context.Context) error {
+	statuses := map[string]Status{}
+	for _, jrd := range c.Source.Records() {
+		for _, link := range jrd.Links {
+			if !checkable(link.Href) {
+				continue
+			}
+			if _, seen := statuses[link.Href]; seen {
+				continue
+			}
+			statuses[link.Href] = c.check(ctx, link.Href)
+		}
+	}
+
+	broken := 0
+	for _, status := range statuses {
+		if !status.OK {
+			broken++
+		}
+	}
+	log.Printf("Checked %d links, %d broken", len(statuses), broken)
+
+	c.mu.Lock()
+	c.statuses = statuses
+	c.broken = broken
+	c.mu.Unlock()
+	return ctx.Err()
+}
+
+// checkable reports whether href is an http or https URL. Others, such as
+// mailto: links, can't be checked with a HEAD request.
+func checkable(href string) bool {
+	u, err := url.Parse(href)
+	if err != nil {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	return (scheme == "http" || scheme == "https") && u.Host != ""
+}
+
+func (c *Checker) check(ctx context.Context, href string) Status {
+	status := Status{CheckedAt: time.Now()}
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, href, nil)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	response, err := c.Client.Do(request)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		status.Error = fmt.Sprintf("HTTP %d", response.StatusCode)
+		return status
+	}
+	status.OK = true
+	return status
+}
+
+// Broken returns the broken hrefs among links with the reason they failed.
+func (c *Checker) Broken(links []api.Link) map[string]string {
+	broken := map[string]string{}
+	if c == nil {
+		return broken
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, link := range links {
+		if status, ok := c.statuses[link.Href]; ok && !status.OK {
+			broken[link.Href] = status.Error
+		}
+	}
+	return broken
+}
+
+// Metrics returns the counts of the last check, for metrics.Handler.
+func (c *Checker) Metrics() []metrics.Metric {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return []metrics.Metric{
+		{
+			Name:    "asdf_links_checked",
+			Help:    "Distinct link targets checked by the last link check.",
+			Type:    metrics.Gauge,
+			Samples: []metrics.Sample{{Value: float64(len(c.statuses))}},
+		},
+		{
+			Name:    "asdf_links_broken",
+			Help:    "Link targets found broken by the last link check.",
+			Type:    metrics.Gauge,
+			Samples: []metrics.Sample{{Value: float64(c.broken)}},
+		},
+	}
+}
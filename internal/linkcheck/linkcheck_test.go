@@ -0,0 +1,46 @@
+package linkcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/0dayfall/asdf/internal/api"
+	"github.com/stretchr/testify/require"
+)
+
+type records []api.JRD
+
+func (r records) Records() []api.JRD {
+	return r
+}
+
+func TestCheckAllFlagsBrokenLinks(t *testing.T) {
+	// Arrange
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer target.Close()
+	links := []api.Link{
+		{Rel: "ok", Href: target.URL + "/profile"},
+		{Rel: "missing", Href: target.URL + "/missing"},
+		{Rel: "mail", Href: "mailto:example@example.com"},
+		{Rel: "chat", Href: "xmpp:example@example.com"},
+	}
+	checker := NewChecker(records{{Subject: "acct:example@example.com", Links: links}})
+
+	// Act
+	err := checker.CheckAll(context.Background())
+	require.NoError(t, err)
+
+	// Assert
+	require.Equal(t, map[string]string{target.URL + "/missing": "HTTP 404"}, checker.Broken(links))
+	metrics := checker.Metrics()
+	require.Equal(t, "asdf_links_checked", metrics[0].Name)
+	require.Equal(t, float64(2), metrics[0].Samples[0].Value)
+	require.Equal(t, "asdf_links_broken", metrics[1].Name)
+	require.Equal(t, float64(1), metrics[1].Samples[0].Value)
+}
@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"log/syslog"
+	"os"
+	"runtime"
+	"time"
+)
+
+// Setup points the standard logger and slog, used throughout the server, at
+// output in the given format, see Open for output; format is "text" or
+// "json". Messages below level, see ParseLevel, are dropped. Lines of the
+// standard logger have level info, errors and warnings are logged with
+// Errorf and Warnf. The returned Closer releases the output on shutdown.
+func Setup(format, level, output string) (io.Closer, error) {
+	minLevel, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	out, closer, err := Open(output)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &slog.HandlerOptions{AddSource: true, Level: minLevel}
+	switch format {
+	case "", "text":
+		slog.SetDefault(slog.New(slog.NewTextHandler(out, options)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(out, options)))
+	default:
+		closer.Close()
+		return nil, fmt.Errorf("asdf: unknown log format %q", format)
+	}
+	return closer, nil
+}
+
+// Output logs msg at level through slog. calldepth is the number of callers
+// to skip for the source location, as for log.Output, 1 is the caller of
+// Output.
+func Output(calldepth int, level slog.Level, msg string) {
+	ctx := context.Background()
+	logger := slog.Default()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(calldepth+1, pcs[:])
+	logger.Handler().Handle(ctx, slog.NewRecord(time.Now(), level, msg, pcs[0]))
+}
+
+// Errorf logs at error level, see Output.
+func Errorf(format string, v ...any) {
+	Output(2, slog.LevelError, fmt.Sprintf(format, v...))
+}
+
+// Warnf logs at warn level, see Output.
+func Warnf(format string, v ...any) {
+	Output(2, slog.LevelWarn, fmt.Sprintf(format, v...))
+}
+
+// Debugf logs at debug level, see Output.
+func Debugf(format string, v ...any) {
+	Output(2, slog.LevelDebug, fmt.Sprintf(format, v...))
+}
+
+// ParseLevel parses "debug", "info", "warn" or "error", empty is info.
+func ParseLevel(level string) (slog.Level, error) {
+	switch level {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("asdf: unknown log level %q", level)
+	}
+}
+
+// Open returns the writer for output, which is "stdout", "stderr", "syslog"
+// or a file path that is appended to, and the Closer releasing it.
+func Open(output string) (io.Writer, io.Closer, error) {
+	switch output {
+	case "", "stderr":
+		return os.Stderr, io.NopCloser(nil), nil
+	case "stdout":
+		return os.Stdout, io.NopCloser(nil), nil
+	case "syslog":
+		writer, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, "asdf")
+		if err != nil {
+			return nil, nil, fmt.Errorf("asdf: connecting to syslog: %w", err)
+		}
+		return writer, writer, nil
+	default:
+		file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+		if err != nil {
+			return nil, nil, fmt.Errorf("asdf: opening log file: %w", err)
+		}
+		return file, file, nil
+	}
+}
@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// restoreLoggers puts the standard and slog loggers back after Setup.
+func restoreLoggers(t *testing.T) {
+	t.Helper()
+	writer, flags, logger := log.Writer(), log.Flags(), slog.Default()
+	t.Cleanup(func() {
+		slog.SetDefault(logger)
+		log.SetOutput(writer)
+		log.SetFlags(flags)
+	})
+}
+
+func TestSetup(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		format string
+		level  string
+		// contains is expected in the output after logging "hello" through
+		// the standard logger and "failed", "careful" and "details" with
+		// Errorf, Warnf and Debugf
+		contains    []string
+		notContains []string
+	}{
+		{
+			name: "text", format: "",
+			contains:    []string{"level=INFO", "msg=hello", "level=ERROR", "msg=failed", "msg=careful", "logging_test.go"},
+			notContains: []string{"details"},
+		},
+		{
+			name: "json", format: "json",
+			contains:    []string{`"level":"INFO"`, `"msg":"hello"`, `"level":"ERROR"`, `"msg":"failed"`, `"level":"WARN"`},
+			notContains: []string{"details"},
+		},
+		{
+			name: "json debug", format: "json", level: "debug",
+			contains: []string{`"msg":"hello"`, `"msg":"failed"`, `"level":"DEBUG"`, `"msg":"details"`},
+		},
+		{
+			name: "text warn", format: "text", level: "warn",
+			contains:    []string{"msg=failed", "msg=careful"},
+			notContains: []string{"hello", "details"},
+		},
+		{
+			name: "json error", format: "json", level: "error",
+			contains:    []string{`"msg":"failed"`},
+			notContains: []string{"hello", "careful", "details"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			restoreLoggers(t)
+			output := filepath.Join(t.TempDir(), "asdf.log")
+
+			// Act
+			closer, err := Setup(test.format, test.level, output)
+			require.NoError(t, err)
+			log.Print("hello")
+			Errorf("failed")
+			Warnf("careful")
+			Debugf("details")
+			require.NoError(t, closer.Close())
+
+			// Assert
+			content, err := os.ReadFile(output)
+			require.NoError(t, err)
+			for _, expected := range test.contains {
+				require.Contains(t, string(content), expected)
+			}
+			for _, unexpected := range test.notContains {
+				require.NotContains(t, string(content), unexpected)
+			}
+		})
+	}
+}
+
+func TestSetupRejectsUnknownSettings(t *testing.T) {
+	for _, test := range []struct {
+		format, level, err string
+	}{
+		{format: "xml", err: `unknown log format "xml"`},
+		{level: "verbose", err: `unknown log level "verbose"`},
+		{level: "INFO", err: `unknown log level "INFO"`},
+	} {
+		// Arrange
+		restoreLoggers(t)
+
+		// Act
+		_, err := Setup(test.format, test.level, filepath.Join(t.TempDir(), "asdf.log"))
+
+		// Assert
+		require.EqualError(t, err, "asdf: "+test.err)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for level, expected := range map[string]slog.Level{
+		"":      slog.LevelInfo,
+		"debug": slog.LevelDebug,
+		"info":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	} {
+		parsed, err := ParseLevel(level)
+		require.NoError(t, err, level)
+		require.Equal(t, expected, parsed, level)
+	}
+}
@@ -0,0 +1,125 @@
+// Package metrics serves counters and gauges in the Prometheus text
+// exposition format, without depending on a client library.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/0dayfall/asdf/internal/logging"
+)
+
+// Metric types
+const (
+	Counter = "counter"
+	Gauge   = "gauge"
+)
+
+// Metric is a family of samples sharing a name.
+type Metric struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Sample is a value of a metric, told apart from the others of its family
+// by Labels.
+type Sample struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// Collector returns the current values of some metrics. It is called on
+// every scrape.
+type Collector func() []Metric
+
+// Handler serves the metrics of its collectors.
+type Handler struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+func NewHandler() *Handler {
+	return &Handler{}
+}
+
+// Add registers a collector.
+func (h *Handler) Add(collect Collector) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.collectors = append(h.collectors, collect)
+}
+
+// Router is the part of *http.ServeMux that RegisterRoutes needs.
+type Router interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+func (h *Handler) RegisterRoutes(mux Router) {
+	mux.Handle("GET /metrics", h)
+}
+
+// ServeHTTP writes every metric, ordered by name.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	collectors := append([]Collector(nil), h.collectors...)
+	h.mu.Unlock()
+
+	var all []Metric
+	for _, collect := range collectors {
+		all = append(all, collect()...)
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+
+	var buf bytes.Buffer
+	for _, metric := range all {
+		write(&buf, metric)
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		logging.Errorf("Error writing metrics: %v", err)
+	}
+}
+
+func write(buf *bytes.Buffer, metric Metric) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", metric.Name, escape(metric.Help, false))
+	fmt.Fprintf(buf, "# TYPE %s %s\n", metric.Name, metric.Type)
+	for _, sample := range metric.Samples {
+		buf.WriteString(metric.Name)
+		if len(sample.Labels) > 0 {
+			names := make([]string, 0, len(sample.Labels))
+			for name := range sample.Labels {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			buf.WriteByte('{')
+			for i, name := range names {
+				if i > 0 {
+					buf.WriteByte(',')
+				}
+				fmt.Fprintf(buf, "%s=\"%s\"", name, escape(sample.Labels[name], true))
+			}
+			buf.WriteByte('}')
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatFloat(sample.Value, 'g', -1, 64))
+		buf.WriteByte('\n')
+	}
+}
+
+// escape escapes backslashes and newlines, and in label values double
+// quotes, as the text format requires.
+func escape(value string, quotes bool) string {
+	replacements := []string{`\`, `\\`, "\n", `\n`}
+	if quotes {
+		replacements = append(replacements, `"`, `\"`)
+	}
+	return strings.NewReplacer(replacements...).Replace(value)
+}
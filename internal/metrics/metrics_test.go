@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	// Arrange
+	handler := NewHandler()
+	handler.Add(func() []Metric {
+		return []Metric{{
+			Name: "asdf_b_total",
+			Help: "Second metric",
+			Type: Counter,
+			Samples: []Sample{
+				{Labels: map[string]string{"job": `say "hi"`, "a": "x\\y"}, Value: 3},
+			},
+		}}
+	})
+	handler.Add(func() []Metric {
+		return []Metric{{Name: "asdf_a", Help: "First\nmetric", Type: Gauge, Samples: []Sample{{Value: 0.5}}}}
+	})
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+	rr := httptest.NewRecorder()
+
+	// Act
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	// Assert
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rr.Header().Get("Content-Type"))
+	require.Equal(t, `# HELP asdf_a First\nmetric
+# TYPE asdf_a gauge
+asdf_a 0.5
+# HELP asdf_b_total Second metric
+# TYPE asdf_b_total counter
+asdf_b_total{a="x\\y",job="say \"hi\""} 3
+`, rr.Body.String())
+}
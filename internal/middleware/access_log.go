@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/0dayfall/asdf/internal/logging"
+)
+
+// Access log formats
+const (
+	// AccessLogCombined is the Apache combined log format
+	AccessLogCombined = "combined"
+	AccessLogJSON     = "json"
+)
+
+// AccessLog writes a line per request to Out, separate from the server's own
+// log. Client errors and server errors are always logged, other responses
+// only with probability SampleRate so busy servers can log a fraction of them.
+type AccessLog struct {
+	Out    io.Writer
+	Format string
+	// SampleRate is between 0 and 1, 1 logs every response
+	SampleRate float64
+	// IP returns the client address of a request
+	IP KeyFunc
+
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+func NewAccessLog(out io.Writer, format string, sampleRate float64, ip KeyFunc) (*AccessLog, error) {
+	switch format {
+	case "":
+		format = AccessLogCombined
+	case AccessLogCombined, AccessLogJSON:
+	default:
+		return nil, fmt.Errorf("asdf: unknown access log format %q", format)
+	}
+	if sampleRate < 0 || sampleRate > 1 {
+		return nil, fmt.Errorf("asdf: access log sample rate %v must be between 0 and 1", sampleRate)
+	}
+	return &AccessLog{Out: out, Format: format, SampleRate: sampleRate, IP: ip, now: time.Now}, nil
+}
+
+// accessLogEntry is a line of the JSON format.
+type accessLogEntry struct {
+	Time      string  `json:"time"`
+	RemoteIP  string  `json:"remote_ip"`
+	Method    string  `json:"method"`
+	URI       string  `json:"uri"`
+	Proto     string  `json:"proto"`
+	Status    int     `json:"status"`
+	Bytes     int64   `json:"bytes"`
+	Duration  float64 `json:"duration_ms"`
+	Referer   string  `json:"referer,omitempty"`
+	UserAgent string  `json:"user_agent,omitempty"`
+	RequestID string  `json:"request_id,omitempty"`
+}
+
+// statusRecorder remembers the status and size of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Handler logs the requests served by next. Place it inside RequestID to get
+// the request IDs in the JSON format.
+func (al *AccessLog) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := al.now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		if rec.status < http.StatusBadRequest && al.SampleRate < 1 && rand.Float64() >= al.SampleRate {
+			return
+		}
+		al.write(r, rec, started)
+	})
+}
+
+func (al *AccessLog) write(r *http.Request, rec *statusRecorder, started time.Time) {
+	var line []byte
+	switch al.Format {
+	case AccessLogJSON:
+		encoded, err := json.Marshal(accessLogEntry{
+			Time:      started.UTC().Format(time.RFC3339Nano),
+			RemoteIP:  al.IP(r),
+			Method:    r.Method,
+			URI:       r.RequestURI,
+			Proto:     r.Proto,
+			Status:    rec.status,
+			Bytes:     rec.bytes,
+			Duration:  float64(al.now().Sub(started).Microseconds()) / 1000,
+			Referer:   r.Referer(),
+			UserAgent: r.UserAgent(),
+			RequestID: RequestIDFromContext(r.Context()),
+		})
+		if err != nil {
+			logging.Errorf("Error encoding access log entry: %v", err)
+			return
+		}
+		line = append(encoded, '\n')
+	default:
+		size := "-"
+		if rec.bytes > 0 {
+			size = strconv.FormatInt(rec.bytes, 10)
+		}
+		line = fmt.Appendf(nil, "%s - - [%s] %s %d %s %s %s\n",
+			al.IP(r), started.Format("02/Jan/2006:15:04:05 -0700"),
+			strconv.Quote(r.Method+" "+r.RequestURI+" "+r.Proto),
+			rec.status, size, strconv.Quote(orDash(r.Referer())), strconv.Quote(orDash(r.UserAgent())))
+	}
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	if _, err := al.Out.Write(line); err != nil {
+		logging.Errorf("Error writing access log: %v", err)
+	}
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
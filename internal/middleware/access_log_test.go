@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func serveLogged(al *AccessLog, status int) {
+	handler := RequestID(al.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte("body"))
+	})))
+	r := httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?resource=acct:alice@example.com", nil)
+	r.Header.Set("User-Agent", "Mastodon/4.2")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	// Arrange
+	var out bytes.Buffer
+	al, err := NewAccessLog(&out, "", 1, func(r *http.Request) string { return "192.0.2.1" })
+	require.NoError(t, err)
+	al.now = func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	// Act
+	serveLogged(al, http.StatusOK)
+
+	// Assert
+	require.Equal(t, `192.0.2.1 - - [01/Mar/2024:12:00:00 +0000] "GET /.well-known/webfinger?resource=acct:alice@example.com HTTP/1.1" 200 4 "-" "Mastodon/4.2"`+"\n", out.String())
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	// Arrange
+	var out bytes.Buffer
+	al, err := NewAccessLog(&out, AccessLogJSON, 1, func(r *http.Request) string { return "192.0.2.1" })
+	require.NoError(t, err)
+
+	// Act
+	serveLogged(al, http.StatusNotFound)
+
+	// Assert
+	var entry accessLogEntry
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	require.Equal(t, http.StatusNotFound, entry.Status)
+	require.Equal(t, int64(4), entry.Bytes)
+	require.Equal(t, "Mastodon/4.2", entry.UserAgent)
+	require.NotEmpty(t, entry.RequestID)
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	// Arrange
+	var out bytes.Buffer
+	al, err := NewAccessLog(&out, AccessLogJSON, 0, func(r *http.Request) string { return "192.0.2.1" })
+	require.NoError(t, err)
+
+	// Act
+	serveLogged(al, http.StatusOK)
+	serveLogged(al, http.StatusInternalServerError)
+
+	// Assert
+	require.Equal(t, 1, bytes.Count(out.Bytes(), []byte("\n")))
+	require.Contains(t, out.String(), `"status":500`)
+}
+
+func TestNewAccessLogInvalid(t *testing.T) {
+	_, err := NewAccessLog(&bytes.Buffer{}, "common", 1, nil)
+	require.Error(t, err)
+	_, err = NewAccessLog(&bytes.Buffer{}, AccessLogJSON, 2, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0dayfall/asdf/internal/apierror"
+)
+
+// Limit allows Requests per Period for each key, with bursts up to Requests.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit parses limits such as "60/m", "10/s" or "1000/h".
+func ParseLimit(spec string) (Limit, error) {
+	count, unit, ok := strings.Cut(spec, "/")
+	requests, err := strconv.Atoi(count)
+	if !ok || err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("asdf: invalid rate limit %q", spec)
+	}
+	periods := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+	period, ok := periods[unit]
+	if !ok {
+		return Limit{}, fmt.Errorf("asdf: invalid rate limit unit in %q, use s, m or h", spec)
+	}
+	return Limit{Requests: requests, Period: period}, nil
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// KeyFunc picks the bucket a request is counted against.
+type KeyFunc func(r *http.Request) string
+
+// RateLimiter is a token bucket limiter with one bucket per key. Separate
+// limiters are used for separate groups of routes.
+type RateLimiter struct {
+	Limit Limit
+	Key   KeyFunc
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+func NewRateLimiter(limit Limit, key KeyFunc) *RateLimiter {
+	return &RateLimiter{Limit: limit, Key: key, buckets: map[string]*bucket{}, now: time.Now}
+}
+
+// Allow takes a token from the bucket of key. If none is left it returns
+// false and how long until the next token.
+func (rl *RateLimiter) Allow(key string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	capacity := float64(rl.Limit.Requests)
+	perToken := rl.Limit.Period / time.Duration(rl.Limit.Requests)
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		rl.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+float64(now.Sub(b.last))/float64(perToken))
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// Cleanup drops buckets that have refilled completely, they behave the same
+// as a new bucket. It is meant to run as a periodic job.
+func (rl *RateLimiter) Cleanup(ctx context.Context) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := rl.now()
+	for key, b := range rl.buckets {
+		if now.Sub(b.last) >= rl.Limit.Period {
+			delete(rl.buckets, key)
+		}
+	}
+	return nil
+}
+
+// Handler rejects requests over the limit with 429 and a Retry-After header.
+func (rl *RateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, retryAfter := rl.Allow(rl.Key(r))
+		if !allowed {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			apierror.Write(w, apierror.RateLimited, "")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
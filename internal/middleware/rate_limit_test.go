@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("60/m")
+	require.NoError(t, err)
+	require.Equal(t, Limit{Requests: 60, Period: time.Minute}, limit)
+
+	for _, spec := range []string{"", "60", "0/s", "x/s", "10/d"} {
+		_, err := ParseLimit(spec)
+		require.Error(t, err, spec)
+	}
+}
+
+func TestRateLimiterPerKey(t *testing.T) {
+	// Arrange
+	now := time.Now()
+	limiter := NewRateLimiter(Limit{Requests: 2, Period: time.Minute}, func(r *http.Request) string {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		return host
+	})
+	limiter.now = func() time.Time { return now }
+	handler := limiter.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/.well-known/webfinger", nil)
+		r.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+		return rr
+	}
+
+	// Act & Assert
+	require.Equal(t, http.StatusOK, request("192.0.2.1:1000").Code)
+	require.Equal(t, http.StatusOK, request("192.0.2.1:1001").Code)
+	limited := request("192.0.2.1:1002")
+	require.Equal(t, http.StatusTooManyRequests, limited.Code)
+	require.Equal(t, "30", limited.Header().Get("Retry-After"))
+	require.Equal(t, http.StatusOK, request("192.0.2.2:1000").Code)
+
+	now = now.Add(30 * time.Second)
+	require.Equal(t, http.StatusOK, request("192.0.2.1:1003").Code)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, limiter.Cleanup(context.Background()))
+	require.Empty(t, limiter.buckets)
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"github.com/0dayfall/asdf/internal/apierror"
+	"github.com/0dayfall/asdf/internal/logging"
+)
+
+// Recover turns a panicking handler into a logged 500 problem response
+// instead of a dropped connection.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			// http.ErrAbortHandler is the documented way to abort a response
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			logging.Errorf("[%s] Panic serving %s %s: %v\n%s", RequestIDFromContext(r.Context()), r.Method, r.URL.Path, v, debug.Stack())
+			apierror.Write(w, apierror.Internal, "")
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/0dayfall/asdf/internal/apierror"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecover(t *testing.T) {
+	// Arrange
+	handler := RequestID(Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("secret internals")
+	})))
+	rr := httptest.NewRecorder()
+
+	// Act
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// Assert
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.Equal(t, apierror.ContentType, rr.Header().Get("Content-Type"))
+	require.NotContains(t, rr.Body.String(), "secret internals")
+	var problem apierror.Problem
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+	require.Equal(t, apierror.Internal, problem.Code)
+	require.Equal(t, rr.Header().Get(RequestIDHeader), problem.RequestID)
+}
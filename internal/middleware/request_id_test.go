@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		reused   bool
+	}{
+		{"generated", "", false},
+		{"incoming", "abc-123", true},
+		{"invalid incoming", "abc 123\nforged", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			var seen string
+			handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestIDFromContext(r.Context())
+			}))
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.incoming != "" {
+				request.Header.Set(RequestIDHeader, test.incoming)
+			}
+			rr := httptest.NewRecorder()
+
+			// Act
+			handler.ServeHTTP(rr, request)
+
+			// Assert
+			require.NotEmpty(t, seen)
+			require.Equal(t, seen, rr.Header().Get(RequestIDHeader))
+			if test.reused {
+				require.Equal(t, test.incoming, seen)
+			} else {
+				require.NotEqual(t, test.incoming, seen)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+// Package openapi serves the OpenAPI 3 document describing the HTTP API and
+// an interactive page to browse and try it.
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/0dayfall/asdf/internal/logging"
+)
+
+const (
+	SpecPath = "/api/openapi.json"
+	DocsPath = "/api/docs"
+)
+
+// Spec is the OpenAPI document. It is kept by hand next to the handlers, a
+// test checks that it lists exactly the registered routes.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// docsPage is the interactive documentation page, rendered once from Spec.
+// Its script and styles are inline, so the page loads nothing from other
+// hosts.
+var docsPage = renderDocs()
+
+//go:embed docs.html
+var docsTemplate string
+
+// docs is what docs.html shows of Spec.
+type docs struct {
+	Title, Description, Version, SpecPath string
+	Operations                            []docsOperation
+}
+
+type docsOperation struct {
+	Method, Path, Summary string
+	// Parameters include the fields of form request bodies, in "body"
+	Parameters []docsParameter
+	Responses  []docsResponse
+}
+
+type docsParameter struct {
+	Name, In, Description, Example string
+	Required                       bool
+}
+
+type docsResponse struct {
+	Status, Description string
+}
+
+// renderDocs renders docs.html for Spec, with the operations ordered by path
+// and method. Both are embedded, so it panics on errors, which the tests
+// catch.
+func renderDocs() []byte {
+	var spec struct {
+		Info struct {
+			Title, Description, Version string
+		}
+		Paths map[string]map[string]struct {
+			Summary     string
+			Parameters  []docsParameter
+			RequestBody struct {
+				Content map[string]struct {
+					Schema struct {
+						Properties map[string]json.RawMessage
+						Required   []string
+					}
+				}
+			}
+			Responses map[string]struct {
+				Description string
+				Ref         string `json:"$ref"`
+			}
+		}
+		Components struct {
+			Responses map[string]struct {
+				Description string
+			}
+		}
+	}
+	if err := json.Unmarshal(Spec, &spec); err != nil {
+		panic("asdf: decoding OpenAPI document: " + err.Error())
+	}
+
+	result := docs{Title: spec.Info.Title, Description: spec.Info.Description, Version: spec.Info.Version, SpecPath: SpecPath}
+	for path, operations := range spec.Paths {
+		for method, operation := range operations {
+			entry := docsOperation{
+				Method:     strings.ToUpper(method),
+				Path:       path,
+				Summary:    operation.Summary,
+				Parameters: operation.Parameters,
+			}
+			form := operation.RequestBody.Content["application/x-www-form-urlencoded"].Schema
+			for name := range form.Properties {
+				entry.Parameters = append(entry.Parameters, docsParameter{
+					Name:     name,
+					In:       "body",
+					Required: slices.Contains(form.Required, name),
+				})
+			}
+			for status, response := range operation.Responses {
+				description := response.Description
+				if description == "" {
+					// A shared response, described by its component
+					name := response.Ref[strings.LastIndex(response.Ref, "/")+1:]
+					description = spec.Components.Responses[name].Description
+				}
+				entry.Responses = append(entry.Responses, docsResponse{Status: status, Description: description})
+			}
+			sort.Slice(entry.Responses, func(i, j int) bool { return entry.Responses[i].Status < entry.Responses[j].Status })
+			result.Operations = append(result.Operations, entry)
+		}
+	}
+	sort.Slice(result.Operations, func(i, j int) bool {
+		a, b := result.Operations[i], result.Operations[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Method < b.Method
+	})
+
+	var buf bytes.Buffer
+	if err := template.Must(template.New("docs").Parse(docsTemplate)).Execute(&buf, result); err != nil {
+		panic("asdf: rendering API docs: " + err.Error())
+	}
+	return buf.Bytes()
+}
+
+// Router is the part of *http.ServeMux that RegisterRoutes needs.
+type Router interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+func RegisterRoutes(mux Router) {
+	mux.Handle("GET "+SpecPath, http.HandlerFunc(SpecHandler))
+	mux.Handle("GET "+DocsPath, http.HandlerFunc(DocsHandler))
+}
+
+// SpecHandler writes the OpenAPI document.
+func SpecHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if _, err := w.Write(Spec); err != nil {
+		logging.Errorf("Error writing OpenAPI document: %v", err)
+	}
+}
+
+// DocsHandler writes the interactive documentation page, on which the
+// operations of the OpenAPI document can be tried against this server.
+func DocsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := w.Write(docsPage); err != nil {
+		logging.Errorf("Error writing API docs: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0dayfall/asdf/internal/health"
+	"github.com/0dayfall/asdf/internal/metrics"
+	"github.com/0dayfall/asdf/internal/rest"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder []string
+
+func (r *recorder) Handle(pattern string, handler http.Handler) {
+	*r = append(*r, pattern)
+}
+
+func TestSpecMatchesRoutes(t *testing.T) {
+	// Arrange
+	var routes recorder
+	(&rest.WebFingerHandler{}).RegisterRoutes(&routes)
+	health.NewHandler(time.Second).RegisterRoutes(&routes)
+	metrics.NewHandler().RegisterRoutes(&routes)
+	RegisterRoutes(&routes)
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(Spec, &spec))
+
+	// Act
+	var documented []string
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	// Assert
+	sort.Strings(routes)
+	sort.Strings(documented)
+	require.Equal(t, []string(routes), documented)
+}
+
+func TestSpecHandler(t *testing.T) {
+	// Arrange
+	mux := http.NewServeMux()
+	RegisterRoutes(mux)
+	req := httptest.NewRequest(http.MethodGet, SpecPath, nil)
+	rr := httptest.NewRecorder()
+
+	// Act
+	mux.ServeHTTP(rr, req)
+
+	// Assert
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	var spec map[string]interface{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &spec))
+	require.Equal(t, "3.0.3", spec["openapi"])
+}
+
+func TestDocsHandler(t *testing.T) {
+	// Arrange
+	mux := http.NewServeMux()
+	RegisterRoutes(mux)
+	req := httptest.NewRequest(http.MethodGet, DocsPath, nil)
+	rr := httptest.NewRecorder()
+
+	// Act
+	mux.ServeHTTP(rr, req)
+
+	// Assert
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+	require.Contains(t, rr.Body.String(), "<code>GET /.well-known/webfinger</code>")
+	require.Contains(t, rr.Body.String(), "<td>Too many requests from this client</td>")
+	require.Contains(t, rr.Body.String(), `<form class="try" data-method="GET" data-path="/.well-known/webfinger">`)
+	require.Contains(t, rr.Body.String(), `<input name="resource" data-in="query" value="acct:example@example.com" required>`)
+	require.Contains(t, rr.Body.String(), `<input name="acct" data-in="body" value="" required>`)
+	// Nothing is loaded from other hosts
+	require.NotContains(t, rr.Body.String(), "src=")
+	require.NotContains(t, rr.Body.String(), `rel="stylesheet"`)
+}
@@ -2,14 +2,41 @@ package resource
 
 import (
 	"errors"
+	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/unicode/norm"
 )
 
+// ErrMissing is returned when a request has no resource parameter.
+var ErrMissing = errors.New("asdf: missing resource parameter")
+
+// Error describes why a resource was rejected. Handlers answer it with 400.
+type Error struct {
+	Resource string
+	Reason   string
+}
+
+func (e *Error) Error() string {
+	return "asdf: invalid resource parameter: " + e.Reason
+}
+
+func invalid(resource, reason string) error {
+	return &Error{Resource: resource, Reason: reason}
+}
+
+// ParseResource returns the canonical form of the resource parameter of
+// request, see GetSubject.
 func ParseResource(request *http.Request) (string, error) {
 	resource := request.URL.Query().Get("resource")
 	if resource == "" {
-		return "", errors.New("asdf: missing resource parameter")
+		return "", ErrMissing
 	}
 
 	acct, err := GetSubject(resource)
@@ -20,14 +47,185 @@ func ParseResource(request *http.Request) (string, error) {
 	return acct, nil
 }
 
+// GetSubject validates resource and returns its canonical form, which is
+// what records are matched on. acct: URIs (RFC 7565), and bare user@host as
+// sent by many clients, become user@host with a percent-decoded, NFC
+// normalized and lowercased user part, so account lookups ignore case.
+// http:, https: and mailto: URIs keep their scheme, lowercased. Hosts are
+// mapped per UTS #46 to their ASCII form, so a domain matches whether it is
+// sent in Unicode or punycode.
 func GetSubject(resource string) (string, error) {
-	acct := strings.TrimPrefix(resource, "acct:")
-	if !IsValidResource(acct) {
-		return "", errors.New("asdf: invalid resource parameter")
+	scheme, rest, hasScheme := strings.Cut(resource, ":")
+	if !hasScheme || strings.Contains(scheme, "@") {
+		// A bare user@host, possibly with a port-like suffix after the @
+		scheme, rest = "acct", resource
+	}
+
+	switch strings.ToLower(scheme) {
+	case "acct":
+		return parseAcct(resource, rest)
+	case "mailto":
+		acct, err := parseAcct(resource, rest)
+		if err != nil {
+			return "", err
+		}
+		return "mailto:" + acct, nil
+	case "http", "https":
+		return parseURL(resource)
+	default:
+		return "", invalid(resource, "unsupported scheme "+scheme)
 	}
-	return acct, nil
 }
 
+// parseAcct checks the acctURI syntax of RFC 7565 section 7.
+func parseAcct(resource, acct string) (string, error) {
+	at := strings.LastIndex(acct, "@")
+	if at < 0 {
+		return "", invalid(resource, "missing @")
+	}
+	userpart, host := acct[:at], acct[at+1:]
+	if userpart == "" {
+		return "", invalid(resource, "empty user part")
+	}
+	if !validUserpart(userpart) {
+		return "", invalid(resource, "invalid character in user part")
+	}
+	host, ok := CanonicalHost(host)
+	if !ok {
+		return "", invalid(resource, "invalid host")
+	}
+	user, err := url.PathUnescape(userpart)
+	if err != nil {
+		return "", invalid(resource, "invalid percent-encoding")
+	}
+	if !utf8.ValidString(user) {
+		return "", invalid(resource, "invalid UTF-8 in user part")
+	}
+	// Decoded subjects end up in logs and pages, control characters could
+	// forge log lines there
+	if strings.ContainsFunc(user, unicode.IsControl) {
+		return "", invalid(resource, "control character in user part")
+	}
+	return strings.ToLower(norm.NFC.String(user)) + "@" + host, nil
+}
+
+func parseURL(resource string) (string, error) {
+	u, err := url.Parse(resource)
+	if err != nil {
+		return "", invalid(resource, "malformed URI")
+	}
+	if u.Host == "" {
+		return "", invalid(resource, "invalid host")
+	}
+	host, ok := CanonicalHost(u.Hostname())
+	if !ok {
+		return "", invalid(resource, "invalid host")
+	}
+	if port := u.Port(); port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = host
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+// validUserpart allows unreserved characters, sub-delims and percent-encoded
+// octets, not starting with a percent-encoded octet. Clients often send
+// non-ASCII characters unencoded, so UTF-8 is accepted as well.
+func validUserpart(userpart string) bool {
+	if !utf8.ValidString(userpart) {
+		return false
+	}
+	for i := 0; i < len(userpart); i++ {
+		c := userpart[i]
+		switch {
+		case isUnreserved(c) || strings.IndexByte("!$&'()*+,;=", c) >= 0 || c >= utf8.RuneSelf:
+		case c == '%' && i > 0 && i+2 < len(userpart) && isHex(userpart[i+1]) && isHex(userpart[i+2]):
+			i += 2
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// CanonicalHost returns the ASCII form of host, mapped per UTS #46 so case
+// and Unicode variants of a domain agree, and reports whether it is valid.
+// IPv6 literals, bracketed or not, must parse as IPv6 addresses and are
+// returned in their canonical text form.
+func CanonicalHost(host string) (string, bool) {
+	if inner, ok := strings.CutPrefix(host, "["); ok {
+		inner, ok = strings.CutSuffix(inner, "]")
+		if !ok {
+			return "", false
+		}
+		addr, ok := parseIPv6(inner)
+		if !ok {
+			return "", false
+		}
+		return "[" + addr + "]", true
+	}
+	if strings.Contains(host, ":") {
+		return parseIPv6(host)
+	}
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil || !validHost(ascii) {
+		return "", false
+	}
+	return ascii, true
+}
+
+// parseIPv6 returns the canonical form of an IPv6 address without zone.
+func parseIPv6(literal string) (string, bool) {
+	addr, err := netip.ParseAddr(literal)
+	if err != nil || !addr.Is6() || addr.Zone() != "" {
+		return "", false
+	}
+	return addr.String(), true
+}
+
+// validHost allows DNS names and IPv4 addresses.
+func validHost(host string) bool {
+	if host == "" {
+		return false
+	}
+	for i := 0; i < len(host); i++ {
+		c := host[i]
+		if !(isAlphaNum(c) || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return !strings.HasPrefix(host, ".") && !strings.Contains(host, "..")
+}
+
+// IsValidResource reports whether resource is accepted by GetSubject.
 func IsValidResource(resource string) bool {
-	return strings.Contains(resource, "@")
+	_, err := GetSubject(resource)
+	return err == nil
+}
+
+// Host returns the host of a canonical resource as returned by GetSubject.
+func Host(canonical string) string {
+	if strings.HasPrefix(canonical, "http://") || strings.HasPrefix(canonical, "https://") {
+		if u, err := url.Parse(canonical); err == nil {
+			return u.Hostname()
+		}
+		return ""
+	}
+	return canonical[strings.LastIndex(canonical, "@")+1:]
+}
+
+func isAlphaNum(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+func isUnreserved(c byte) bool {
+	return isAlphaNum(c) || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
 }
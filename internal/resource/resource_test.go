@@ -1,6 +1,7 @@
 package resource
 
 import (
+	"errors"
 	"net/http"
 	"net/url"
 	"testing"
@@ -31,9 +32,87 @@ func TestXResource(t *testing.T) {
 	httpRequest := http.Request{URL: parsedURL}
 
 	// Act
-	resource, err := ParseResource(&httpRequest)
+	_, err := ParseResource(&httpRequest)
 
 	//Evaluate
-	require.NoError(t, err)
-	require.Equal(t, "@asdf", resource)
+	var invalid *Error
+	require.True(t, errors.As(err, &invalid))
+	require.Equal(t, "empty user part", invalid.Reason)
+}
+
+func TestMissingResource(t *testing.T) {
+	parsedURL, _ := url.Parse("https://example.com/.well-known/webfinger")
+	_, err := ParseResource(&http.Request{URL: parsedURL})
+	require.ErrorIs(t, err, ErrMissing)
+}
+
+func TestGetSubject(t *testing.T) {
+	tests := []struct {
+		resource string
+		expected string
+	}{
+		{"acct:alice@example.com", "alice@example.com"},
+		{"acct:alice@EXAMPLE.com", "alice@example.com"},
+		{"acct:Alice@Example.com", "alice@example.com"},
+		{"mailto:Alice@example.com", "mailto:alice@example.com"},
+		{"https://example.com/Alice", "https://example.com/Alice"},
+		{"acct:JOSÉ@example.com", "josé@example.com"},
+		{"ACCT:alice@example.com", "alice@example.com"},
+		{"alice@example.com", "alice@example.com"},
+		{"acct:juliet%40capulet.example@shoppingsite.example", "juliet@capulet.example@shoppingsite.example"},
+		{"acct:first.last+tag@example.com", "first.last+tag@example.com"},
+		{"mailto:alice@Example.com", "mailto:alice@example.com"},
+		{"https://Example.com/profile/foo#me", "https://example.com/profile/foo"},
+		{"HTTP://example.com:8080/~foo", "http://example.com:8080/~foo"},
+		{"https://example.com/@alice", "https://example.com/@alice"},
+		{"acct:josé@bücher.example", "josé@xn--bcher-kva.example"},
+		{"acct:josé@xn--bcher-kva.example", "josé@xn--bcher-kva.example"},
+		{"acct:jos%C3%A9@BÜCHER.example", "josé@xn--bcher-kva.example"},
+		{"https://bücher.example:8443/josé", "https://xn--bcher-kva.example:8443/jos%C3%A9"},
+		{"acct:jose\u0301@example.com", "jos\u00e9@example.com"},
+		{"http://[::1]/alice", "http://[::1]/alice"},
+		{"acct:alice@[::1]", "alice@[::1]"},
+		{"acct:alice@[2001:DB8::0001]", "alice@[2001:db8::1]"},
+	}
+	for _, test := range tests {
+		subject, err := GetSubject(test.resource)
+		require.NoError(t, err, test.resource)
+		require.Equal(t, test.expected, subject, test.resource)
+	}
+}
+
+func TestGetSubjectInvalid(t *testing.T) {
+	for _, resource := range []string{
+		"alice",
+		"acct:alice",
+		"acct:@example.com",
+		"acct:alice@",
+		"acct:al ice@example.com",
+		"acct:%41lice@example.com",
+		"acct:alice@exa_mple.com",
+		"acct:jos%C3@example.com",
+		"acct:alice@xn--zz.example",
+		"acct:alice@example..com",
+		"acct:a@[<script>x</script>]",
+		"acct:a%0Ab@example.com",
+		"acct:alice@[127.0.0.1]",
+		"acct:alice@[fe80::1%eth0]",
+		"acct:alice@[::1",
+		"acct:alice@[]",
+		"http://[fe80::1%25eth0]/alice",
+		"https:///profile",
+		"ftp://example.com/alice",
+		"urn:isbn:0451450523",
+	} {
+		_, err := GetSubject(resource)
+		var invalid *Error
+		require.True(t, errors.As(err, &invalid), resource)
+		require.False(t, IsValidResource(resource), resource)
+	}
+}
+
+func TestHost(t *testing.T) {
+	require.Equal(t, "example.com", Host("alice@example.com"))
+	require.Equal(t, "example.com", Host("mailto:alice@example.com"))
+	require.Equal(t, "example.com", Host("https://example.com:8443/@alice"))
 }
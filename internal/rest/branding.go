@@ -0,0 +1,49 @@
+package rest
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+)
+
+const BrandingPath = "/api/branding"
+
+// Branding is what a hosted instance may change on the HTML pages without
+// overriding the templates. Empty fields keep the defaults.
+type Branding struct {
+	// Title is the page title and heading
+	Title string `json:"title"`
+	// LogoURL is shown above the heading when set
+	LogoURL string `json:"logo_url,omitempty"`
+	// Color is a CSS hex color for the heading
+	Color string `json:"color,omitempty"`
+	// Footer is shown unescaped at the bottom of every page, it comes from
+	// the operator's configuration only
+	Footer template.HTML `json:"footer"`
+}
+
+var defaultBranding = Branding{
+	Title:  "Welcome to web finger",
+	Footer: "&copy; 2023 Web Finger Web Site. All rights reserved.",
+}
+
+// withDefaults returns b with the defaults filled in.
+func (b Branding) withDefaults() Branding {
+	if b.Title == "" {
+		b.Title = defaultBranding.Title
+	}
+	if b.Footer == "" {
+		b.Footer = defaultBranding.Footer
+	}
+	return b
+}
+
+// BrandingHandler writes the branding as JSON for frontends rendering their
+// own pages.
+func (p *Pages) BrandingHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(ContentType, "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if err := json.NewEncoder(w).Encode(p.Brand); err != nil {
+		logf(r, "Error writing branding: %v", err)
+	}
+}
@@ -1,66 +1,140 @@
 package rest
 
 import (
+	"errors"
+	"html/template"
 	"net/http"
-	"path"
-	"text/template"
+	"sync/atomic"
 
-	"github.com/gorilla/sessions"
+	"github.com/0dayfall/asdf/internal/api"
+	"github.com/0dayfall/asdf/internal/apierror"
+	"github.com/0dayfall/asdf/internal/resource"
+	"github.com/0dayfall/asdf/web"
 )
 
-var templatePath = path.Join("web", "template")
+// Pages renders the HTML pages from the embedded templates, or those
+// overridden in Dir, with Brand. Servers each build their own.
+type Pages struct {
+	// Dir optionally overrides the embedded templates and static assets, see
+	// web.Templates
+	Dir   string
+	Brand Branding
 
-var accountTmpl *template.Template
-var searchTmpl *template.Template
-
-func LoadTemplates() {
-	accountTmpl = template.Must(template.ParseFiles(path.Join(templatePath, "account.html")))
-	searchTmpl = template.Must(template.ParseFiles(path.Join(templatePath, "search.html")))
+	// The templates are swapped as a whole by Reload while requests are
+	// rendering them.
+	account atomic.Pointer[template.Template]
+	search  atomic.Pointer[template.Template]
 }
 
-type HTMLHandler struct {
-	store *sessions.CookieStore
+// NewPages parses the templates of dir, which may be empty for only the
+// embedded ones.
+func NewPages(dir string, brand Branding) (*Pages, error) {
+	pages := &Pages{Dir: dir, Brand: brand.withDefaults()}
+	if err := pages.Reload(); err != nil {
+		return nil, err
+	}
+	return pages, nil
 }
 
-func (wfh *WebFingerHandler) HTMLHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		IndexHandler(w, r)
-	case http.MethodPost:
-		wfh.SearchHandler(w, r)
-	case http.MethodPut:
-		// Handle PUT request
-	case http.MethodPatch:
-		// Handle PATCH request
-	case http.MethodDelete:
-		// Handle DELETE request
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// embeddedPages serves WebFingerHandlers without Pages. The embedded
+// templates are parsed here so those work without loading anything first.
+var embeddedPages = func() *Pages {
+	pages, err := NewPages("", Branding{})
+	if err != nil {
+		panic(err)
+	}
+	return pages
+}()
+
+// Reload parses the templates again. The current templates stay in use
+// unless all of them parse.
+func (p *Pages) Reload() error {
+	templates := web.Templates(p.Dir)
+	account, err := template.ParseFS(templates, "account.html")
+	if err != nil {
+		return err
+	}
+	search, err := template.ParseFS(templates, "search.html")
+	if err != nil {
+		return err
 	}
+	p.account.Store(account)
+	p.search.Store(search)
+	return nil
+}
+
+// StaticHandler serves the static assets, with those in Dir, below /static.
+func (p *Pages) StaticHandler() http.Handler {
+	return http.StripPrefix("/static", http.FileServerFS(web.Static(p.Dir)))
 }
 
-func IndexHandler(w http.ResponseWriter, r *http.Request) {
-	// Render Go template
-	err := searchTmpl.Execute(w, nil)
+// accountView is the data rendered by the account template.
+type accountView struct {
+	*api.JRD
+	BrokenLinks map[string]string
+	Brand       Branding
+}
+
+// searchView is the data rendered by the search template.
+type searchView struct {
+	NotFound string
+	Brand    Branding
+}
+
+// contentTypeHTML is set before rendering, so browsers never have to sniff
+// the pages.
+const contentTypeHTML = "text/html; charset=utf-8"
+
+func (p *Pages) IndexHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(ContentType, contentTypeHTML)
+	err := p.search.Load().Execute(w, searchView{Brand: p.Brand})
 	if err != nil {
-		http.Error(w, "Error rendering template to search", http.StatusInternalServerError)
+		logf(r, "Error rendering search template: %v", err)
+		apierror.Write(w, apierror.Internal, "Error rendering template to search")
 	}
 }
 
 func (wfh *WebFingerHandler) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	pages := wfh.pages()
 	subject, err := getSubjectFromForm(r)
 	if err != nil {
-		http.Error(w, "Error parsing form", http.StatusInternalServerError)
+		apierror.Write(w, apierror.BadRequest, "Error parsing form")
+		return
+	}
+	subject, err = resource.GetSubject(subject)
+	if err != nil {
+		apierror.Write(w, apierror.InvalidResource, err.Error())
+		return
 	}
 
-	webFingerData, err := wfh.Data.LookupResource(subject)
+	webFingerData, err := wfh.lookup(subject)
+	if errors.Is(err, errGone) {
+		webFingerData, err = nil, nil
+	}
 	if err != nil {
-		http.Error(w, "Error lookup resource", http.StatusInternalServerError)
+		logf(r, "Error looking up %s: %v", subject, err)
+		apierror.Write(w, apierror.Internal, "Error lookup resource")
+		return
+	}
+	w.Header().Set(ContentType, contentTypeHTML)
+	if webFingerData == nil {
+		w.WriteHeader(http.StatusNotFound)
+		err = pages.search.Load().Execute(w, searchView{NotFound: subject, Brand: pages.Brand})
+		if err != nil {
+			logf(r, "Error rendering search template: %v", err)
+		}
+		return
 	}
 
-	err = accountTmpl.Execute(w, webFingerData)
+	view := accountView{
+		JRD:         webFingerData,
+		BrokenLinks: wfh.Links.Broken(webFingerData.Links),
+		Brand:       pages.Brand,
+	}
+	err = pages.account.Load().Execute(w, view)
 	if err != nil {
-		http.Error(w, "Error rendering template to display account", http.StatusInternalServerError)
+		logf(r, "Error rendering account template: %v", err)
+		apierror.Write(w, apierror.Internal, "Error rendering template to display account")
 	}
 }
 
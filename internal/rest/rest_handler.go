@@ -1,13 +1,25 @@
 package rest
 
 import (
-	"asdf/internal/api"
-	"asdf/internal/db"
-	"asdf/internal/resource"
 	"bytes"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/0dayfall/asdf/internal/access"
+	"github.com/0dayfall/asdf/internal/api"
+	"github.com/0dayfall/asdf/internal/apierror"
+	"github.com/0dayfall/asdf/internal/db"
+	"github.com/0dayfall/asdf/internal/domains"
+	"github.com/0dayfall/asdf/internal/linkcheck"
+	"github.com/0dayfall/asdf/internal/logging"
+	"github.com/0dayfall/asdf/internal/middleware"
+	"github.com/0dayfall/asdf/internal/resource"
 )
 
 const (
@@ -15,40 +27,159 @@ const (
 	ContentTypeJRD = "application/jrd+json"
 )
 
+const WELL_KNOWN_WEBFINGER = "/.well-known/webfinger"
+
 type WebFingerHandler struct {
-	Data *db.Data
+	Data  db.Store
+	Links *linkcheck.Checker
+	// Domains restricts lookups to served domains, nil serves all
+	Domains *domains.Registry
+
+	// Optional rate limiters for the WebFinger endpoint and the HTML pages
+	WebFingerLimiter *middleware.RateLimiter
+	HTMLLimiter      *middleware.RateLimiter
+	// Access optionally denies or limits WebFinger clients by policy, it
+	// falls back to WebFingerLimiter
+	Access *access.Policies
+	// Pages renders the HTML pages, the embedded templates when nil
+	Pages *Pages
+}
+
+func (wfh *WebFingerHandler) pages() *Pages {
+	if wfh.Pages == nil {
+		return embeddedPages
+	}
+	return wfh.Pages
+}
+
+// Router is the part of *http.ServeMux that RegisterRoutes needs.
+type Router interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+// RegisterRoutes adds the WebFinger endpoint and the HTML pages to mux.
+// Requests with a method not registered for a path get a 405 from the mux.
+func (wfh *WebFingerHandler) RegisterRoutes(mux Router) {
+	wfh.RegisterWebFinger(mux)
+	wfh.RegisterPages(mux)
+}
+
+// RegisterWebFinger adds only the WebFinger endpoint to mux.
+func (wfh *WebFingerHandler) RegisterWebFinger(mux Router) {
+	webFinger := middleware.AllowAnyOrigin(wfh.Access.Handler(wfh.WebFingerLimiter, wfh))
+	mux.Handle("GET "+WELL_KNOWN_WEBFINGER, webFinger)
+	mux.Handle("OPTIONS "+WELL_KNOWN_WEBFINGER, webFinger)
+}
+
+// RegisterPages adds the HTML search pages, their static assets and branding
+// to mux.
+func (wfh *WebFingerHandler) RegisterPages(mux Router) {
+	pages := wfh.pages()
+	mux.Handle("GET /static/", pages.StaticHandler())
+	mux.Handle("GET "+BrandingPath, http.HandlerFunc(pages.BrandingHandler))
+	mux.Handle("GET /", limit(wfh.HTMLLimiter, http.HandlerFunc(pages.IndexHandler)))
+	mux.Handle("POST /", limit(wfh.HTMLLimiter, http.HandlerFunc(wfh.SearchHandler)))
+}
+
+func limit(limiter *middleware.RateLimiter, handler http.Handler) http.Handler {
+	if limiter == nil {
+		return handler
+	}
+	return limiter.Handler(handler)
 }
 
 func (wfh *WebFingerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	acct, err := resource.ParseResource(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	var invalid *resource.Error
+	if errors.Is(err, resource.ErrMissing) || errors.As(err, &invalid) {
+		apierror.Write(w, apierror.InvalidResource, err.Error())
+		return
+	} else if err != nil {
+		logf(r, "Error parsing resource: %v", err)
+		apierror.Write(w, apierror.Internal, "")
 		return
 	}
 
-	jrd, err := wfh.Data.LookupResource(acct)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	jrd, err := wfh.lookup(acct)
+	if errors.Is(err, errGone) {
+		// Tells other servers to stop retrying, unlike a 404
+		w.WriteHeader(http.StatusGone)
+		return
+	} else if err != nil {
+		logf(r, "Error looking up %s: %v", acct, err)
+		apierror.Write(w, apierror.Internal, "")
+		return
+	}
+	if jrd == nil {
+		// RFC 7033 section 4.2, no JRD for unknown resources
+		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
-	writeResponse(w, jrd)
+	writeResponse(w, r, jrd)
 }
 
-func writeResponse(w http.ResponseWriter, content *api.JRD) {
-	w.Header().Set(ContentType, ContentTypeJRD)
+// errGone is returned by lookup for records that have expired. They are kept
+// as tombstones until the store purges them.
+var errGone = errors.New("asdf: record expired")
+
+// lookup returns the record for a canonical resource with the defaults of
+// its domain applied. It returns nil without an error for unknown resources,
+// including those of domains not served here, and errGone for expired ones.
+func (wfh *WebFingerHandler) lookup(subject string) (*api.JRD, error) {
+	if !wfh.Domains.Serves(resource.Host(subject)) {
+		return nil, nil
+	}
+	jrd, err := wfh.Data.LookupResource(subject)
+	if err != nil || jrd == nil {
+		return nil, err
+	}
+	if jrd.Expired(time.Now()) {
+		return nil, errGone
+	}
+	jrd = wfh.Domains.Apply(jrd)
+	jrd.ExpiresAt = nil
+	return jrd, nil
+}
 
+// logf logs an error prefixed with the request ID of r.
+func logf(r *http.Request, format string, v ...interface{}) {
+	if id := middleware.RequestIDFromContext(r.Context()); id != "" {
+		format = "[" + id + "] " + format
+	}
+	logging.Output(2, slog.LevelError, fmt.Sprintf(format, v...))
+}
+
+// bufferPool holds the response buffers of writeResponse, saving an
+// allocation and its growth on every lookup.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// maxPooledBuffer keeps buffers grown by unusually large records out of the
+// pool so they don't stay allocated.
+const maxPooledBuffer = 64 << 10
+
+func writeResponse(w http.ResponseWriter, r *http.Request, content *api.JRD) {
 	// Use a buffer, should the encoding fail, we don't want to send a partial response
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(content); err != nil {
-		log.Printf("Error writing body: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			bufferPool.Put(buf)
+		}
+	}()
+
+	if err := json.NewEncoder(buf).Encode(content); err != nil {
+		logf(r, "Error encoding body: %v", err)
+		apierror.Write(w, apierror.Internal, "")
 		return
 	}
 
+	w.Header().Set(ContentType, ContentTypeJRD)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
 	w.WriteHeader(http.StatusOK)
 	if _, err := buf.WriteTo(w); err != nil {
-		log.Printf("Error writing body: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		logf(r, "Error writing body: %v", err)
 	}
 }
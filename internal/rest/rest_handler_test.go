@@ -1,15 +1,22 @@
 package rest
 
 import (
-	"asdf/internal/api"
-	"asdf/internal/db"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
 	"path"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/0dayfall/asdf/internal/api"
+	"github.com/0dayfall/asdf/internal/db"
+	"github.com/0dayfall/asdf/internal/domains"
 	"github.com/stretchr/testify/require"
 )
 
@@ -65,6 +72,278 @@ func TestGETResourceEmpty(t *testing.T) {
 
 		// Assert
 		require.EqualValues(t, http.StatusBadRequest, rr.Code)
-		require.EqualValues(t, "text/plain; charset=utf-8", rr.Header().Get("Content-Type"))
+		require.EqualValues(t, "application/problem+json", rr.Header().Get("Content-Type"))
 	})
 }
+
+func TestRoutesMethodNotAllowed(t *testing.T) {
+	// Arrange
+	db := db.NewData()
+	err := db.LoadData(path.Join("test", "data.json"))
+	require.NoError(t, err)
+	wfh := WebFingerHandler{Data: db}
+	mux := http.NewServeMux()
+	wfh.RegisterRoutes(mux)
+
+	rr := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodDelete, "/.well-known/webfinger?resource=acct:example@example.com", nil)
+	require.NoError(t, err)
+
+	// Act
+	mux.ServeHTTP(rr, request)
+
+	// Assert
+	require.EqualValues(t, http.StatusMethodNotAllowed, rr.Code)
+	require.Contains(t, rr.Header().Get("Allow"), http.MethodGet)
+}
+
+func TestWebFingerCORS(t *testing.T) {
+	// Arrange
+	db := db.NewData()
+	err := db.LoadData(path.Join("test", "data.json"))
+	require.NoError(t, err)
+	wfh := WebFingerHandler{Data: db}
+	mux := http.NewServeMux()
+	wfh.RegisterRoutes(mux)
+
+	get := httptest.NewRecorder()
+	getRequest, err := http.NewRequest(http.MethodGet, "/.well-known/webfinger?resource=acct:example@example.com", nil)
+	require.NoError(t, err)
+	getRequest.Header.Set("Origin", "https://app.example.org")
+
+	preflight := httptest.NewRecorder()
+	preflightRequest, err := http.NewRequest(http.MethodOptions, "/.well-known/webfinger", nil)
+	require.NoError(t, err)
+	preflightRequest.Header.Set("Origin", "https://app.example.org")
+	preflightRequest.Header.Set("Access-Control-Request-Method", http.MethodGet)
+
+	// Act
+	mux.ServeHTTP(get, getRequest)
+	mux.ServeHTTP(preflight, preflightRequest)
+
+	// Assert
+	require.EqualValues(t, http.StatusOK, get.Code)
+	require.EqualValues(t, "*", get.Header().Get("Access-Control-Allow-Origin"))
+	require.EqualValues(t, http.StatusNoContent, preflight.Code)
+	require.EqualValues(t, "*", preflight.Header().Get("Access-Control-Allow-Origin"))
+	require.Contains(t, preflight.Header().Get("Access-Control-Allow-Methods"), http.MethodGet)
+}
+
+func TestGETResourceForeignDomain(t *testing.T) {
+	// Arrange
+	db := db.NewData()
+	err := db.LoadData(path.Join("test", "data.json"))
+	require.NoError(t, err)
+	registry, err := domains.NewRegistry([]string{"example.org"}, "")
+	require.NoError(t, err)
+	wfh := WebFingerHandler{Data: db, Domains: registry}
+
+	rr := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/.well-known/webfinger?resource=acct:example@example.com", nil)
+	require.NoError(t, err)
+
+	// Act
+	wfh.ServeHTTP(rr, request)
+
+	// Assert
+	require.EqualValues(t, http.StatusNotFound, rr.Code)
+}
+
+func TestGETResourceLinkTemplate(t *testing.T) {
+	// Arrange
+	db := db.NewData()
+	err := db.LoadData(path.Join("test", "data.json"))
+	require.NoError(t, err)
+	wfh := WebFingerHandler{Data: db}
+
+	rr := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/.well-known/webfinger?resource=acct:templated@example.com", nil)
+	require.NoError(t, err)
+
+	// Act
+	wfh.ServeHTTP(rr, request)
+
+	// Assert
+	require.EqualValues(t, http.StatusOK, rr.Code)
+	expectedJSON := `{"subject":"acct:templated@example.com","links":[{"rel":"http://ostatus.org/schema/1.0/subscribe","template":"https://example.com/authorize_interaction?uri={uri}","titles":{"en-us":"Follow","und":"Follow"},"properties":{"http://example.com/prop/kind":"subscribe","http://example.com/prop/unset":null}}]}`
+	require.JSONEq(t, expectedJSON, rr.Body.String())
+}
+
+type failingStore struct{}
+
+func (failingStore) LookupResource(subject string) (*api.JRD, error) {
+	return nil, errors.New("backend unavailable")
+}
+
+func (failingStore) Records() []api.JRD {
+	return nil
+}
+
+func TestWebFingerStatus(t *testing.T) {
+	data := db.NewData()
+	err := data.LoadData(path.Join("test", "data.json"))
+	require.NoError(t, err)
+	registry, err := domains.NewRegistry([]string{"example.com"}, "")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		store db.Store
+		query string
+		code  int
+	}{
+		{"found", data, "?resource=acct:example@example.com", http.StatusOK},
+		{"found by alias", data, "?resource=http://example.com/profile/example", http.StatusOK},
+		{"unknown account", data, "?resource=acct:nobody@example.com", http.StatusNotFound},
+		{"foreign domain", data, "?resource=acct:example@example.org", http.StatusNotFound},
+		{"missing resource", data, "", http.StatusBadRequest},
+		{"malformed resource", data, "?resource=acct:nobody", http.StatusBadRequest},
+		{"unsupported scheme", data, "?resource=ftp://example.com/nobody", http.StatusBadRequest},
+		{"backend failure", failingStore{}, "?resource=acct:example@example.com", http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Arrange
+			wfh := WebFingerHandler{Data: test.store, Domains: registry}
+			rr := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/.well-known/webfinger"+test.query, nil)
+			require.NoError(t, err)
+
+			// Act
+			wfh.ServeHTTP(rr, request)
+
+			// Assert
+			require.EqualValues(t, test.code, rr.Code)
+			if test.code == http.StatusOK {
+				require.EqualValues(t, "application/jrd+json", rr.Header().Get("Content-Type"))
+			} else {
+				require.NotEqual(t, "application/jrd+json", rr.Header().Get("Content-Type"))
+			}
+			if test.code == http.StatusNotFound {
+				require.Empty(t, rr.Body.String())
+			}
+			if test.code == http.StatusBadRequest {
+				require.Contains(t, rr.Body.String(), `"code":"invalid_resource"`)
+			}
+			if test.code == http.StatusInternalServerError {
+				require.NotContains(t, rr.Body.String(), "backend unavailable")
+				require.Contains(t, rr.Body.String(), `"code":"internal_error"`)
+			}
+		})
+	}
+}
+
+func BenchmarkWebFinger(b *testing.B) {
+	data := db.NewData()
+	err := data.LoadData(path.Join("test", "data.json"))
+	require.NoError(b, err)
+	wfh := WebFingerHandler{Data: data}
+	request, err := http.NewRequest(http.MethodGet, "/.well-known/webfinger?resource=acct:example@example.com", nil)
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rr := httptest.NewRecorder()
+		wfh.ServeHTTP(rr, request)
+		if rr.Code != http.StatusOK {
+			b.Fatalf("unexpected status %d", rr.Code)
+		}
+	}
+}
+
+func TestPagesWebDir(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "template"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "template", "search.html"), []byte("custom search"), 0o644))
+	pages, err := NewPages(dir, Branding{})
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	(&WebFingerHandler{Pages: pages}).RegisterPages(mux)
+
+	// Act
+	index := httptest.NewRecorder()
+	mux.ServeHTTP(index, httptest.NewRequest(http.MethodGet, "/", nil))
+	style := httptest.NewRecorder()
+	mux.ServeHTTP(style, httptest.NewRequest(http.MethodGet, "/static/style.css", nil))
+
+	// Assert
+	require.Equal(t, "custom search", index.Body.String())
+	require.Equal(t, http.StatusOK, style.Code)
+	require.Contains(t, style.Body.String(), "font-family")
+}
+
+func TestBranding(t *testing.T) {
+	// Arrange
+	pages, err := NewPages("", Branding{Title: "Example Directory", Color: "#336699"})
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	(&WebFingerHandler{Pages: pages}).RegisterPages(mux)
+
+	// Act
+	index := httptest.NewRecorder()
+	mux.ServeHTTP(index, httptest.NewRequest(http.MethodGet, "/", nil))
+	api := httptest.NewRecorder()
+	mux.ServeHTTP(api, httptest.NewRequest(http.MethodGet, BrandingPath, nil))
+
+	// Assert
+	require.Contains(t, index.Body.String(), "<title>Example Directory</title>")
+	require.Contains(t, index.Body.String(), "color: #336699")
+	var branding Branding
+	require.NoError(t, json.Unmarshal(api.Body.Bytes(), &branding))
+	require.Equal(t, "Example Directory", branding.Title)
+	require.Equal(t, defaultBranding.Footer, branding.Footer)
+}
+
+func TestGETResourceExpiry(t *testing.T) {
+	// Arrange
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	db := db.NewData()
+	db.Put(api.JRD{Subject: "acct:temp@example.com", ExpiresAt: &past})
+	db.Put(api.JRD{Subject: "acct:later@example.com", ExpiresAt: &future})
+	wfh := WebFingerHandler{Data: db}
+
+	// Act
+	expired := httptest.NewRecorder()
+	wfh.ServeHTTP(expired, httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?resource=acct:temp@example.com", nil))
+	current := httptest.NewRecorder()
+	wfh.ServeHTTP(current, httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?resource=acct:later@example.com", nil))
+
+	// Assert
+	require.Equal(t, http.StatusGone, expired.Code)
+	require.Equal(t, http.StatusOK, current.Code)
+	require.NotContains(t, current.Body.String(), "expires_at")
+}
+
+func TestPagesEscapeHTML(t *testing.T) {
+	// Arrange
+	db := db.NewData()
+	db.Put(api.JRD{
+		Subject:    "acct:alice@example.com",
+		Properties: map[string]interface{}{"http://example.com/prop/name": "<script>alert(1)</script>"},
+	})
+	wfh := WebFingerHandler{Data: db}
+	search := func(acct string) *httptest.ResponseRecorder {
+		form := url.Values{"acct": {acct}}
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		wfh.SearchHandler(rr, request)
+		return rr
+	}
+
+	// Act
+	notFound := search("acct:a%3Cscript%3Ealert(1)%3C%2Fscript%3E@example.com")
+	account := search("acct:alice@example.com")
+
+	// Assert
+	require.Equal(t, http.StatusNotFound, notFound.Code)
+	require.Equal(t, http.StatusOK, account.Code)
+	for _, rr := range []*httptest.ResponseRecorder{notFound, account} {
+		require.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+		require.NotContains(t, rr.Body.String(), "<script>")
+		require.Contains(t, rr.Body.String(), "&lt;script&gt;")
+	}
+}
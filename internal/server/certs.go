@@ -0,0 +1,93 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/0dayfall/asdf/internal/logging"
+)
+
+// certStore selects a certificate by SNI hostname. Certificates are read
+// from a directory holding <hostname>.crt and <hostname>.key pairs, the
+// static certificate is used for unknown or missing server names.
+type certStore struct {
+	certPath, keyPath, certDir string
+
+	mu       sync.RWMutex
+	fallback *tls.Certificate
+	byHost   map[string]*tls.Certificate
+}
+
+func newCertStore(certPath, keyPath, certDir string) (*certStore, error) {
+	store := &certStore{certPath: certPath, keyPath: keyPath, certDir: certDir}
+	if err := store.reload(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// reload reads the certificates again, keeping the current ones on error.
+func (cs *certStore) reload() error {
+	fallback, byHost, err := loadCertificates(cs.certPath, cs.keyPath, cs.certDir)
+	if err != nil {
+		return err
+	}
+	cs.mu.Lock()
+	cs.fallback, cs.byHost = fallback, byHost
+	cs.mu.Unlock()
+	return nil
+}
+
+func loadCertificates(certPath, keyPath, certDir string) (*tls.Certificate, map[string]*tls.Certificate, error) {
+	fallback, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("asdf: loading certificate: %w", err)
+	}
+	byHost := map[string]*tls.Certificate{}
+	if certDir == "" {
+		return &fallback, byHost, nil
+	}
+
+	crtFiles, err := filepath.Glob(filepath.Join(certDir, "*.crt"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("asdf: reading certificate directory: %w", err)
+	}
+	for _, crtFile := range crtFiles {
+		host := strings.ToLower(strings.TrimSuffix(filepath.Base(crtFile), ".crt"))
+		keyFile := strings.TrimSuffix(crtFile, ".crt") + ".key"
+		if _, err := os.Stat(keyFile); err != nil {
+			logging.Warnf("Skipping certificate for %s: %v", host, err)
+			continue
+		}
+		cert, err := tls.LoadX509KeyPair(crtFile, keyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("asdf: loading certificate for %s: %w", host, err)
+		}
+		byHost[host] = &cert
+	}
+	return &fallback, byHost, nil
+}
+
+// hosts returns the hostnames with a dedicated certificate.
+func (cs *certStore) hosts() []string {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	hosts := make([]string, 0, len(cs.byHost))
+	for host := range cs.byHost {
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+func (cs *certStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	if cert, ok := cs.byHost[strings.ToLower(hello.ServerName)]; ok {
+		return cert, nil
+	}
+	return cs.fallback, nil
+}
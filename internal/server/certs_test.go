@@ -0,0 +1,66 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestCert(t *testing.T, dir, name, host string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0o600))
+}
+
+func commonName(t *testing.T, cert *tls.Certificate) string {
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return parsed.Subject.CommonName
+}
+
+func TestCertStoreSelectsBySNI(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	certDir := filepath.Join(dir, "certs")
+	require.NoError(t, os.Mkdir(certDir, 0o700))
+	writeTestCert(t, dir, "server", "default.test")
+	writeTestCert(t, certDir, "example.com", "example.com")
+
+	// Act
+	store, err := newCertStore(filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"), certDir)
+	require.NoError(t, err)
+	known, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: "Example.com"})
+	require.NoError(t, err)
+	unknown, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.org"})
+	require.NoError(t, err)
+
+	// Assert
+	require.Equal(t, "example.com", commonName(t, known))
+	require.Equal(t, "default.test", commonName(t, unknown))
+}
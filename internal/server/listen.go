@@ -0,0 +1,75 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// systemd passes activated sockets starting at this file descriptor.
+const listenFdsStart = 3
+
+// listen returns the listeners to serve on. Sockets passed by systemd socket
+// activation take precedence over addr, which is either a TCP address such as
+// ":8080" or a Unix socket path as "unix:///run/asdf.sock".
+func listen(addr string) ([]net.Listener, error) {
+	activated, err := systemdListeners()
+	if err != nil || len(activated) > 0 {
+		return activated, err
+	}
+
+	if socketPath, ok := strings.CutPrefix(addr, "unix://"); ok {
+		// A socket file left behind by an unclean shutdown blocks the bind
+		if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("asdf: removing stale socket: %w", err)
+		}
+		listener, err := net.Listen("unix", socketPath)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{listener}, nil
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return []net.Listener{listener}, nil
+}
+
+// systemdListeners implements the LISTEN_PID/LISTEN_FDS protocol of
+// sd_listen_fds(3).
+func systemdListeners() ([]net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	listeners := make([]net.Listener, 0, count)
+	for fd := listenFdsStart; fd < listenFdsStart+count; fd++ {
+		file := os.NewFile(uintptr(fd), "systemd-socket-"+strconv.Itoa(fd))
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("asdf: using systemd socket %d: %w", fd, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+func listenerAddrs(listeners []net.Listener) []string {
+	addrs := make([]string, len(listeners))
+	for i, listener := range listeners {
+		addrs[i] = listener.Addr().Network() + "://" + listener.Addr().String()
+	}
+	return addrs
+}
@@ -0,0 +1,22 @@
+package server
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenUnixSocket(t *testing.T) {
+	// Arrange
+	socketPath := filepath.Join(t.TempDir(), "asdf.sock")
+
+	// Act
+	listeners, err := listen("unix://" + socketPath)
+	require.NoError(t, err)
+	defer listeners[0].Close()
+
+	// Assert
+	require.Len(t, listeners, 1)
+	require.Equal(t, []string{"unix://" + socketPath}, listenerAddrs(listeners))
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+const acmeChallengePath = "/.well-known/acme-challenge/"
+
+// redirectHandler answers plain HTTP requests with a 301 to the same URL on
+// HTTPS. When acmeDir is set, HTTP-01 challenge files written there by an
+// ACME client are served as is.
+func redirectHandler(httpsPort, acmeDir string) http.Handler {
+	mux := http.NewServeMux()
+	if acmeDir != "" {
+		mux.Handle("GET "+acmeChallengePath, http.StripPrefix(acmeChallengePath, http.FileServer(http.Dir(acmeDir))))
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), httpsPort)
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+	return mux
+}
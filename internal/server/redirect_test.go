@@ -0,0 +1,30 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectHandler(t *testing.T) {
+	// Arrange
+	acmeDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(acmeDir, "token"), []byte("key-authorization"), 0o600))
+	handler := redirectHandler("8443", acmeDir)
+
+	// Act
+	redirect := httptest.NewRecorder()
+	handler.ServeHTTP(redirect, httptest.NewRequest(http.MethodGet, "http://example.com/.well-known/webfinger?resource=acct:a@example.com", nil))
+	challenge := httptest.NewRecorder()
+	handler.ServeHTTP(challenge, httptest.NewRequest(http.MethodGet, "http://example.com/.well-known/acme-challenge/token", nil))
+
+	// Assert
+	require.Equal(t, http.StatusMovedPermanently, redirect.Code)
+	require.Equal(t, "https://example.com:8443/.well-known/webfinger?resource=acct:a@example.com", redirect.Header().Get("Location"))
+	require.Equal(t, http.StatusOK, challenge.Code)
+	require.Equal(t, "key-authorization", challenge.Body.String())
+}
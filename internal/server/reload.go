@@ -0,0 +1,45 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/0dayfall/asdf/internal/logging"
+)
+
+// Reload re-reads the records, templates and certificates, as Start does on
+// SIGHUP. Each part is replaced only if it loads completely, otherwise the
+// running one stays in place. Records given by WithStore are not reloaded.
+func (s *Server) Reload() {
+	var changes, failures []string
+
+	if s.data != nil {
+		records := s.data.Len()
+		if err := s.data.LoadData(s.cfg.DataFile); err != nil {
+			failures = append(failures, fmt.Sprintf("records: %v", err))
+		} else {
+			changes = append(changes, fmt.Sprintf("records %d -> %d", records, s.data.Len()))
+		}
+	}
+
+	if err := s.pages.Reload(); err != nil {
+		failures = append(failures, fmt.Sprintf("templates: %v", err))
+	} else {
+		changes = append(changes, "templates")
+	}
+
+	// The certificates are loaded by Run
+	if certs := s.certs.Load(); certs != nil {
+		hosts := len(certs.hosts())
+		if err := certs.reload(); err != nil {
+			failures = append(failures, fmt.Sprintf("certificates: %v", err))
+		} else {
+			changes = append(changes, fmt.Sprintf("certificates %d -> %d hosts", hosts, len(certs.hosts())))
+		}
+	}
+
+	s.logger.Printf("Reloaded %s", strings.Join(changes, ", "))
+	if len(failures) > 0 {
+		logging.Errorf("Error reloading, kept previous %s", strings.Join(failures, "; "))
+	}
+}
@@ -1,74 +1,364 @@
 package server
 
 import (
-	"asdf/internal/db"
-	"asdf/internal/rest"
 	"context"
 	"crypto/tls"
+	"fmt"
+	"html/template"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"path"
+	"sync/atomic"
 	"syscall"
 	"time"
-)
 
-const WELL_KNOWN_WEBFINGER = "/.well-known/webfinger"
+	"github.com/0dayfall/asdf/internal/access"
+	"github.com/0dayfall/asdf/internal/clientip"
+	"github.com/0dayfall/asdf/internal/config"
+	"github.com/0dayfall/asdf/internal/db"
+	"github.com/0dayfall/asdf/internal/domains"
+	"github.com/0dayfall/asdf/internal/health"
+	"github.com/0dayfall/asdf/internal/jobs"
+	"github.com/0dayfall/asdf/internal/linkcheck"
+	"github.com/0dayfall/asdf/internal/logging"
+	"github.com/0dayfall/asdf/internal/metrics"
+	"github.com/0dayfall/asdf/internal/middleware"
+	"github.com/0dayfall/asdf/internal/openapi"
+	"github.com/0dayfall/asdf/internal/rest"
+)
 
 func init() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 }
 
-func Start(addr, certPath, keyPath string) {
-	stopChan := make(chan os.Signal, 1)
-	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+// Server serves WebFinger as configured by a config.Config. New builds it
+// and Run serves it until its context is done.
+type Server struct {
+	cfg    *config.Config
+	logger *log.Logger
+
+	store db.Store
+	// data is the store loaded from cfg.DataFile, nil when WithStore
+	// replaced it. Only it is reloaded and saved back on shutdown.
+	data *db.Data
+
+	runner  *jobs.Runner
+	pages   *rest.Pages
+	handler http.Handler
+	// noPages leaves the HTML pages out of handler
+	noPages bool
+	// accessLog is closed when Run returns
+	accessLog io.Closer
+	// certs is set by Run and read by Reload from the SIGHUP handler
+	certs atomic.Pointer[certStore]
+}
+
+type Option func(*Server)
+
+// WithStore serves the records of store instead of those in the data file.
+func WithStore(store db.Store) Option {
+	return func(s *Server) { s.store = store }
+}
+
+// WithoutPages serves only the WebFinger endpoint, the health checks and
+// the API documentation, without the HTML search pages.
+func WithoutPages() Option {
+	return func(s *Server) { s.noPages = true }
+}
+
+// WithLogger sets the logger for the messages of the server package itself,
+// such as the startup summary and shutdown, the standard logger by default.
+// Errors and warnings, and everything handlers, jobs and the file store log,
+// go through the logging package so LOG_LEVEL applies to them.
+func WithLogger(logger *log.Logger) Option {
+	return func(s *Server) { s.logger = logger }
+}
+
+// New loads the records and the templates overridden in cfg.WebDir and
+// builds the handler. Nothing is served until Run.
+func New(cfg *config.Config, opts ...Option) (*Server, error) {
+	s := &Server{cfg: cfg, logger: log.Default()}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.store == nil {
+		s.data = db.NewData()
+		if err := s.data.LoadData(cfg.DataFile); err != nil {
+			return nil, fmt.Errorf("asdf: loading data: %w", err)
+		}
+		s.store = s.data
+	}
+
+	clientIPs, err := clientip.NewResolver(cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("asdf: configuring trusted proxies: %w", err)
+	}
+
+	registry, err := domains.NewRegistry(cfg.ServedDomains, cfg.DomainDefaultsFile)
+	if err != nil {
+		return nil, fmt.Errorf("asdf: loading domains: %w", err)
+	}
+
+	s.runner = jobs.NewRunner()
+	if s.data != nil {
+		s.data.TombstoneRetention = cfg.TombstoneRetention
+		purgeInterval := cfg.PurgeInterval
+		if purgeInterval <= 0 {
+			purgeInterval = config.DefaultPurgeInterval
+		}
+		s.runner.Register("purge-expired", purgeInterval, s.data.PurgeExpired)
+	}
+	webFingerHandler := &rest.WebFingerHandler{Data: s.store, Domains: registry}
+	if cfg.LinkCheckInterval > 0 {
+		webFingerHandler.Links = linkcheck.NewChecker(s.store)
+		s.runner.Register("linkcheck", cfg.LinkCheckInterval, webFingerHandler.Links.CheckAll)
+	}
+	if cfg.WebFingerRateLimit.Requests > 0 {
+		webFingerHandler.WebFingerLimiter = middleware.NewRateLimiter(cfg.WebFingerRateLimit, clientIPs.IP)
+		s.runner.Register("ratelimit-webfinger", cfg.WebFingerRateLimit.Period, webFingerHandler.WebFingerLimiter.Cleanup)
+	}
+	if cfg.AccessPolicyFile != "" {
+		webFingerHandler.Access, err = access.Load(cfg.AccessPolicyFile, clientIPs.IP)
+		if err != nil {
+			return nil, fmt.Errorf("asdf: loading access policies: %w", err)
+		}
+		s.runner.Register("ratelimit-access", time.Minute, webFingerHandler.Access.Cleanup)
+	}
+	if cfg.HTMLRateLimit.Requests > 0 {
+		webFingerHandler.HTMLLimiter = middleware.NewRateLimiter(cfg.HTMLRateLimit, clientIPs.IP)
+		s.runner.Register("ratelimit-html", cfg.HTMLRateLimit.Period, webFingerHandler.HTMLLimiter.Cleanup)
+	}
+
+	s.pages, err = rest.NewPages(cfg.WebDir, rest.Branding{
+		Title:   cfg.BrandTitle,
+		LogoURL: cfg.BrandLogoURL,
+		Color:   cfg.BrandColor,
+		Footer:  template.HTML(cfg.BrandFooter),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("asdf: loading templates: %w", err)
+	}
+	webFingerHandler.Pages = s.pages
+	healthHandler := health.NewHandler(2 * time.Second)
+	if s.data != nil {
+		healthHandler.Add("store", func(ctx context.Context) error {
+			// The records are persisted back to this file on shutdown
+			_, err := os.Stat(cfg.DataFile)
+			return err
+		})
+	}
+
+	mux := http.NewServeMux()
+	if s.noPages {
+		webFingerHandler.RegisterWebFinger(mux)
+	} else {
+		webFingerHandler.RegisterRoutes(mux)
+	}
+	healthHandler.RegisterRoutes(mux)
+	metricsHandler := metrics.NewHandler()
+	metricsHandler.Add(s.runner.Metrics)
+	if webFingerHandler.Links != nil {
+		metricsHandler.Add(webFingerHandler.Links.Metrics)
+	}
+	metricsHandler.RegisterRoutes(mux)
+	openapi.RegisterRoutes(mux)
+	var handler http.Handler = middleware.Recover(mux)
+	if cfg.AccessLogOutput != "" {
+		out, closer, err := logging.Open(cfg.AccessLogOutput)
+		if err != nil {
+			return nil, fmt.Errorf("asdf: opening access log: %w", err)
+		}
+		accessLog, err := middleware.NewAccessLog(out, cfg.AccessLogFormat, cfg.AccessLogSample, clientIPs.IP)
+		if err != nil {
+			closer.Close()
+			return nil, err
+		}
+		s.accessLog = closer
+		handler = accessLog.Handler(handler)
+	}
+	s.handler = middleware.RequestID(handler)
+
+	return s, nil
+}
+
+// Handler returns the handler for all routes, with the request ID, access log
+// and panic recovery middleware applied.
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
 
-	db := db.NewData()
-	loadDataErr := db.LoadData(path.Join("data", "data.json"))
-	if loadDataErr != nil {
-		log.Fatalf("Error loading data: %v", loadDataErr)
+// Start serves cfg until SIGINT, SIGTERM or SIGQUIT and reloads on SIGHUP.
+func Start(cfg *config.Config) {
+	s, err := New(cfg)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	// store := sessions.NewCookieStore([]byte(sessionKey))
-	// http.HandleFunc("/login", rest.LoginHandler)
-	// http.HandleFunc("/logout", rest.LogoutHandler)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	defer stop()
 
-	webFingerHandler := &rest.WebFingerHandler{Data: db}
-	http.Handle(WELL_KNOWN_WEBFINGER, webFingerHandler)
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	defer signal.Stop(reloadChan)
+	go func() {
+		for range reloadChan {
+			s.Reload()
+		}
+	}()
 
-	rest.LoadTemplates()
-	http.HandleFunc("/", webFingerHandler.HTMLHandler)
+	if err := s.Run(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
+// Run runs the startup checks, serves HTTPS, or plain HTTP without
+// certificates, and the HTTP redirect if configured, until ctx is done and
+// then shuts down gracefully.
+func (s *Server) Run(ctx context.Context) error {
+	if s.accessLog != nil {
+		defer s.accessLog.Close()
+	}
+	cfg := s.cfg
+	dataFile := cfg.DataFile
+	if s.data == nil {
+		dataFile = ""
+	}
+	checks := runStartupChecks(dataFile, cfg.WebDir, cfg.CertPath, cfg.KeyPath)
+
+	// Without certificates plain HTTP is served, for use behind a TLS proxy
+	var certs *certStore
+	tlsMode := "off"
+	if cfg.CertPath != "" || cfg.CertDir != "" {
+		var err error
+		certs, err = newCertStore(cfg.CertPath, cfg.KeyPath, cfg.CertDir)
+		if err != nil {
+			return fmt.Errorf("asdf: loading certificates: %w", err)
+		}
+		s.certs.Store(certs)
+		tlsMode = "static"
+		if cfg.CertDir != "" {
+			tlsMode = fmt.Sprintf("sni %v", certs.hosts())
+		}
+	}
+
+	listeners, err := listen(cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("asdf: listening: %w", err)
+	}
+
+	listenAddrs := listenerAddrs(listeners)
+	if cfg.HTTPAddr != "" {
+		listenAddrs = append(listenAddrs, "http://"+cfg.HTTPAddr)
+	}
+
+	features := []string{"webfinger"}
+	if !s.noPages {
+		features = append(features, "html")
+	}
+	if cfg.LinkCheckInterval > 0 {
+		features = append(features, "linkcheck")
+	}
+	if cfg.WebFingerRateLimit.Requests > 0 || cfg.HTMLRateLimit.Requests > 0 {
+		features = append(features, "ratelimit")
+	}
+	if cfg.AccessPolicyFile != "" {
+		features = append(features, "access")
+	}
+
+	store := "custom"
+	if s.data != nil {
+		store = "file"
+	}
+	err = logStartupSummary(s.logger, startupSummary{
+		Environment: cfg.Environment,
+		Store:       store,
+		DataFile:    dataFile,
+		Records:     len(s.store.Records()),
+		Listen:      listenAddrs,
+		TLS:         tlsMode,
+		Features:    features,
+		Checks:      checks,
+	}, cfg.IsProduction())
+	if err != nil {
+		closeListeners(listeners)
+		return err
+	}
+
+	// Requests keep their context during the graceful shutdown
+	baseCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
 	defer cancel()
+	s.runner.Start(baseCtx)
+
 	server := &http.Server{
-		Addr:         addr,
+		Handler:      s.handler,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  15 * time.Second,
-		TLSConfig:    &tls.Config{},
-		BaseContext:  func(listener net.Listener) context.Context { return ctx },
+		BaseContext:  func(listener net.Listener) context.Context { return baseCtx },
+	}
+	if certs != nil {
+		server.TLSConfig = &tls.Config{GetCertificate: certs.GetCertificate}
 	}
 
-	go func() {
-		httpServerErr := server.ListenAndServeTLS(certPath, keyPath)
-		if httpServerErr == http.ErrServerClosed {
-			log.Print(httpServerErr)
-		} else {
-			log.Fatalf("HTTPS server error: %v", httpServerErr)
+	serveErr := make(chan error, len(listeners)+1)
+	for _, listener := range listeners {
+		go func(listener net.Listener) {
+			if certs == nil {
+				serveErr <- fmt.Errorf("asdf: HTTP server: %w", server.Serve(listener))
+			} else {
+				serveErr <- fmt.Errorf("asdf: HTTPS server: %w", server.ServeTLS(listener, "", ""))
+			}
+		}(listener)
+	}
+
+	var redirectServer *http.Server
+	if cfg.HTTPAddr != "" {
+		// The HTTPS port to redirect to, empty for Unix sockets behind a proxy
+		_, httpsPort, _ := net.SplitHostPort(cfg.Addr)
+		redirectServer = &http.Server{
+			Addr:         cfg.HTTPAddr,
+			Handler:      redirectHandler(httpsPort, cfg.ACMEChallengeDir),
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  15 * time.Second,
 		}
-	}()
+		go func() {
+			serveErr <- fmt.Errorf("asdf: HTTP server: %w", redirectServer.ListenAndServe())
+		}()
+	}
 
-	<-stopChan
-	log.Println("Shutting down server gracefully..")
-	db.SaveData(path.Join("data", "data.json"))
-	log.Println("Saved data to disk")
-	shutdownErr := server.Shutdown(ctx)
-	if shutdownErr != nil {
-		log.Println("Error shutting down: ", shutdownErr)
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+	}
+
+	s.logger.Println("Shutting down server gracefully..")
+	s.runner.Stop()
+	if s.data != nil {
+		s.data.SaveData(cfg.DataFile)
+		s.logger.Println("Saved data to disk")
+	}
+	shutdownCtx, cancelShutdown := context.WithTimeout(baseCtx, 10*time.Second)
+	defer cancelShutdown()
+	if redirectServer != nil {
+		if shutdownErr := redirectServer.Shutdown(shutdownCtx); shutdownErr != nil {
+			logging.Errorf("Error shutting down HTTP server: %v", shutdownErr)
+		}
+	}
+	if shutdownErr := server.Shutdown(shutdownCtx); shutdownErr != nil {
+		logging.Errorf("Error shutting down: %v", shutdownErr)
 	} else {
-		log.Println("Server shutdown completed")
+		s.logger.Println("Server shutdown completed")
+	}
+	return err
+}
+
+func closeListeners(listeners []net.Listener) {
+	for _, listener := range listeners {
+		listener.Close()
 	}
 }
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/0dayfall/asdf/internal/api"
+	"github.com/0dayfall/asdf/internal/config"
+	"github.com/0dayfall/asdf/internal/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWithStore(t *testing.T) {
+	// Arrange
+	store := db.NewData()
+	store.Put(api.JRD{Subject: "acct:alice@example.com"})
+	s, err := New(&config.Config{DataFile: "does-not-exist.json"}, WithStore(store))
+	require.NoError(t, err)
+
+	// Act
+	found := httptest.NewRecorder()
+	s.Handler().ServeHTTP(found, httptest.NewRequest(http.MethodGet, "/.well-known/webfinger?resource=acct:alice@example.com", nil))
+	ready := httptest.NewRecorder()
+	s.Handler().ServeHTTP(ready, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+
+	// Assert
+	require.Equal(t, http.StatusOK, found.Code)
+	require.NotEmpty(t, found.Header().Get("X-Request-ID"))
+	require.Equal(t, http.StatusOK, ready.Code)
+}
+
+func TestMetricsListJobs(t *testing.T) {
+	// Arrange
+	s, err := New(&config.Config{DataFile: filepath.Join("..", "rest", "test", "data.json")})
+	require.NoError(t, err)
+
+	// Act
+	rr := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	// Assert
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `asdf_job_runs_total{job="purge-expired"} 0`)
+}
+
+func TestNewMissingDataFile(t *testing.T) {
+	_, err := New(&config.Config{DataFile: filepath.Join(t.TempDir(), "missing.json")})
+	require.Error(t, err)
+}
+
+func TestRunStopsWithContext(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	writeTestCert(t, dir, "server", "localhost")
+	var logs bytes.Buffer
+	cfg := &config.Config{
+		Addr:     "127.0.0.1:0",
+		CertPath: filepath.Join(dir, "server.crt"),
+		KeyPath:  filepath.Join(dir, "server.key"),
+	}
+	s, err := New(cfg, WithStore(db.NewData()), WithLogger(log.New(&logs, "", 0)))
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	// Act
+	go func() { done <- s.Run(ctx) }()
+	require.Eventually(t, func() bool { return s.certs.Load() != nil }, 5*time.Second, 10*time.Millisecond)
+	cancel()
+
+	// Assert
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the context was canceled")
+	}
+	require.Contains(t, logs.String(), `"store":"custom"`)
+}
+
+func TestServersKeepTheirOwnPages(t *testing.T) {
+	// Arrange
+	first, err := New(&config.Config{BrandTitle: "First"}, WithStore(db.NewData()))
+	require.NoError(t, err)
+	second, err := New(&config.Config{BrandTitle: "Second"}, WithStore(db.NewData()))
+	require.NoError(t, err)
+
+	// Act
+	firstPage := httptest.NewRecorder()
+	first.Handler().ServeHTTP(firstPage, httptest.NewRequest(http.MethodGet, "/", nil))
+	secondPage := httptest.NewRecorder()
+	second.Handler().ServeHTTP(secondPage, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// Assert
+	require.Contains(t, firstPage.Body.String(), "<title>First</title>")
+	require.Contains(t, secondPage.Body.String(), "<title>Second</title>")
+}
+
+func TestRunPlainHTTPWithoutCertificates(t *testing.T) {
+	// Arrange
+	socket := filepath.Join(t.TempDir(), "asdf.sock")
+	store := db.NewData()
+	store.Put(api.JRD{Subject: "acct:alice@example.com"})
+	s, err := New(&config.Config{Addr: "unix://" + socket}, WithStore(store), WithLogger(log.New(io.Discard, "", 0)))
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+
+	// Act
+	var response *http.Response
+	require.Eventually(t, func() bool {
+		response, err = client.Get("http://asdf/.well-known/webfinger?resource=acct:alice@example.com")
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	response.Body.Close()
+	cancel()
+
+	// Assert
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	require.NoError(t, <-done)
+}
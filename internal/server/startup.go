@@ -0,0 +1,91 @@
+package server
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/0dayfall/asdf/internal/logging"
+)
+
+// startupCheck is the outcome of a single self-check run before serving.
+type startupCheck struct {
+	Name     string `json:"name"`
+	OK       bool   `json:"ok"`
+	Critical bool   `json:"critical"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// startupSummary is logged once on startup as a single JSON line.
+type startupSummary struct {
+	Environment string         `json:"environment"`
+	Store       string         `json:"store"`
+	DataFile    string         `json:"data_file"`
+	Records     int            `json:"records"`
+	Listen      []string       `json:"listen"`
+	TLS         string         `json:"tls"`
+	Features    []string       `json:"features"`
+	Checks      []startupCheck `json:"checks"`
+}
+
+func fileCheck(name, fileName string, critical bool) startupCheck {
+	check := startupCheck{Name: name, Critical: critical}
+	if _, err := os.Stat(fileName); err != nil {
+		check.Detail = err.Error()
+		return check
+	}
+	check.OK = true
+	return check
+}
+
+// runStartupChecks checks the files the server needs. dataFile is empty when
+// the records do not come from a file, webDir when the embedded templates
+// are not overridden and certPath when serving plain HTTP.
+func runStartupChecks(dataFile, webDir, certPath, keyPath string) []startupCheck {
+	var checks []startupCheck
+	if dataFile != "" {
+		checks = append(checks, fileCheck("data_file", dataFile, true))
+	}
+	if webDir != "" {
+		checks = append(checks, fileCheck("web_dir", webDir, true))
+	}
+
+	if certPath == "" {
+		return checks
+	}
+	keyPair := startupCheck{Name: "tls_key_pair", Critical: true}
+	if _, err := tls.LoadX509KeyPair(certPath, keyPath); err != nil {
+		keyPair.Detail = err.Error()
+	} else {
+		keyPair.OK = true
+	}
+	return append(checks, keyPair)
+}
+
+// logStartupSummary writes the summary as JSON and reports whether startup
+// should be refused. Failed critical checks only refuse startup when strict,
+// in production, elsewhere they are logged so local setups keep working.
+func logStartupSummary(logger *log.Logger, summary startupSummary, strict bool) error {
+	encoded, err := json.Marshal(summary)
+	if err != nil {
+		return fmt.Errorf("asdf: encoding startup summary: %w", err)
+	}
+	logger.Printf("startup %s", encoded)
+
+	var failed []string
+	for _, check := range summary.Checks {
+		if !check.OK && check.Critical {
+			failed = append(failed, check.Name)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	if strict {
+		return fmt.Errorf("asdf: refusing to start, failed checks: %v", failed)
+	}
+	logging.Warnf("Failed startup checks: %v", failed)
+	return nil
+}
@@ -0,0 +1,53 @@
+// Package web holds the HTML templates and static assets of the search pages.
+// They are compiled into the binary, so it runs from any directory, and can be
+// customized file by file from an override directory.
+package web
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+//go:embed template static
+var files embed.FS
+
+// Templates returns the templates. Files in dir/template replace the embedded
+// ones of the same name, dir may be empty to use only the embedded ones.
+func Templates(dir string) fs.FS {
+	return overlay(dir, "template")
+}
+
+// Static returns the assets served below /static. Files in dir/static
+// replace the embedded ones of the same name.
+func Static(dir string) fs.FS {
+	return overlay(dir, "static")
+}
+
+func overlay(dir, name string) fs.FS {
+	embedded, err := fs.Sub(files, name)
+	if err != nil {
+		panic(err)
+	}
+	if dir == "" {
+		return embedded
+	}
+	return overlayFS{override: os.DirFS(filepath.Join(dir, name)), base: embedded}
+}
+
+// overlayFS opens files from override and falls back to base for those it
+// does not have.
+type overlayFS struct {
+	override fs.FS
+	base     fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	file, err := o.override.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.base.Open(name)
+	}
+	return file, err
+}